@@ -1,12 +1,19 @@
 package gobuild
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
@@ -18,7 +25,8 @@ const (
 
 //go:generate faux --interface BuildProcess --output fakes/build_process.go
 type BuildProcess interface {
-	Execute(config GoBuildConfiguration) (binaries []string, err error)
+	Execute(config GoBuildConfiguration) (binaries []string, details BuildDetails, err error)
+	CompileTests(config GoBuildConfiguration) (binaries []string, err error)
 }
 
 //go:generate faux --interface PathManager --output fakes/path_manager.go
@@ -29,7 +37,7 @@ type PathManager interface {
 
 //go:generate faux --interface SourceRemover --output fakes/source_remover.go
 type SourceRemover interface {
-	Clear(path string) error
+	Clear(path string, keepFiles []string) error
 }
 
 //go:generate faux --interface SBOMGenerator --output fakes/sbom_generator.go
@@ -37,6 +45,16 @@ type SBOMGenerator interface {
 	Generate(dir string) (sbom.SBOM, error)
 }
 
+//go:generate faux --interface ChecksumCalculator --output fakes/checksum_calculator.go
+type ChecksumCalculator interface {
+	Sum(paths ...string) (string, error)
+}
+
+//go:generate faux --interface Toolchain --output fakes/toolchain.go
+type Toolchain interface {
+	Version() (string, error)
+}
+
 func Build(
 	parser ConfigurationParser,
 	buildProcess BuildProcess,
@@ -45,6 +63,12 @@ func Build(
 	logs scribe.Emitter,
 	sourceRemover SourceRemover,
 	sbomGenerator SBOMGenerator,
+	checksumCalculator ChecksumCalculator,
+	toolchain Toolchain,
+	vulnerabilityScanner VulnerabilityScanner,
+	licenseCollector LicenseCollector,
+	profileResolver ProfileResolver,
+	bindingResolver BindingResolver,
 ) packit.BuildFunc {
 	return func(context packit.BuildContext) (packit.BuildResult, error) {
 		logs.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
@@ -63,6 +87,11 @@ func Build(
 
 		goCacheLayer.Cache = true
 
+		goModCacheLayer, err := context.Layers.Get(GoModCacheLayerName)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
 		// Parse the BuildConfiguration from the environment again since a prior
 		// step may have augmented the configuration.
 		configuration, err := parser.Parse(context.BuildpackInfo.Version, context.WorkingDir)
@@ -75,64 +104,347 @@ func Build(
 			workingDir = filepath.Join(context.WorkingDir, configuration.WorkDir)
 		}
 
-		goPath, path, err := pathManager.Setup(workingDir, configuration.ImportPath)
+		// The platform writes the project metadata to the root of the layers
+		// directory, next to the layers of each buildpack
+		metadata := buildMetadata{
+			workspace:           workingDir,
+			projectMetadataPath: filepath.Join(filepath.Dir(context.Layers.Path), ProjectMetadataFileName),
+		}
+		configuration, err = renderBuildConfiguration(configuration, metadata)
+		if err != nil {
+			return packit.BuildResult{}, packit.Fail.WithMessage("failed to parse build configuration: %w", err)
+		}
+
+		moduleDirs, err := findBuildModules(workingDir, configuration.Targets, configuration.WorkspaceUseModules)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
-		config := GoBuildConfiguration{
-			Workspace:           path,
-			Output:              filepath.Join(targetsLayer.Path, "bin"),
-			GoPath:              goPath,
-			GoCache:             goCacheLayer.Path,
-			Flags:               configuration.Flags,
-			Targets:             configuration.Targets,
-			WorkspaceUseModules: configuration.WorkspaceUseModules,
+
+		err = checkModulePolicy(context.WorkingDir, moduleDirs, configuration)
+		if err != nil {
+			return packit.BuildResult{}, err
 		}
 
-		if isStaticStack(context.Stack) && !containsFlag(config.Flags, "-buildmode") {
-			config.DisableCGO = true
-			config.Flags = append(config.Flags, "-buildmode", "default")
+		moduleSumsSHA, err := calculateModuleSumsSHA(checksumCalculator, workingDir, moduleDirs)
+		if err != nil {
+			return packit.BuildResult{}, err
 		}
 
-		if configuration.WorkDir != "" {
-			logs.Process(fmt.Sprintf("Using BP_GO_WORKDIR variable, build subdirectory is '%s'", configuration.WorkDir))
+		if previous, _ := goModCacheLayer.Metadata[ModuleSumsSHAKey].(string); previous != moduleSumsSHA {
+			goModCacheLayer, err = goModCacheLayer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		goModCacheLayer.Cache = true
+		goModCacheLayer.Metadata = map[string]interface{}{
+			ModuleSumsSHAKey: moduleSumsSHA,
 		}
 
-		binaries, err := buildProcess.Execute(config)
+		workspaceSHA, err := checksumCalculator.Sum(context.WorkingDir)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		err = pathManager.Teardown(goPath)
+		goVersion, err := toolchain.Version()
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		err = sourceRemover.Clear(context.WorkingDir)
+		profiles, err := profileResolver.Resolve(ProfileConfiguration{
+			Workspace:           workingDir,
+			Targets:             configuration.Targets,
+			Flags:               configuration.Flags,
+			TargetConfiguration: configuration.TargetConfiguration,
+			WorkspaceUseModules: configuration.WorkspaceUseModules,
+			Profile:             configuration.PGO,
+			PlatformPath:        context.Platform.Path,
+		})
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		logs.GeneratingSBOM(filepath.Join(targetsLayer.Path, "bin"))
+		bindingsSHA, err := calculateBindingsSHA(bindingResolver, checksumCalculator, context.Platform.Path, configuration.Offline)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 
-		var sbomContent sbom.SBOM
-		duration, err := clock.Measure(func() error {
-			sbomContent, err = sbomGenerator.Generate(filepath.Join(targetsLayer.Path, "bin"))
-			return err
+		var vulnerabilityDatabase string
+		if configuration.VulnCheck {
+			vulnerabilityDatabase, err = vulnerabilityScanner.DatabaseVersion(VulnerabilityScanConfiguration{
+				PlatformPath: context.Platform.Path,
+				Database:     configuration.VulnCheckDatabase,
+			})
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		fingerprint, err := calculateFingerprint(fingerprintInputs{
+			WorkspaceSHA:          workspaceSHA,
+			Profiles:              profiles.Digest,
+			Bindings:              bindingsSHA,
+			VulnerabilityDatabase: vulnerabilityDatabase,
+			Environment:           outputEnvironment(os.Environ()),
+			Configuration:         configuration,
+			GoVersion:             goVersion,
+			Stack:                 context.Stack,
 		})
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
-		logs.Action("Completed in %s", duration.Round(time.Millisecond))
-		logs.Break()
 
-		logs.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
-		targetsLayer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+		var debugSymbolsLayer packit.Layer
+		if configuration.SplitDebugSymbols {
+			debugSymbolsLayer, err = context.Layers.Get(DebugSymbolsLayerName)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			debugSymbolsLayer.Cache = true
+		}
+
+		binaries, testBinaries, ok := cachedBinaries(targetsLayer, fingerprint)
+
+		// The debug symbols are only cached, so they are gone whenever the cache
+		// is, even though the targets layer can still be reused from the image
+		if ok && configuration.SplitDebugSymbols {
+			previous, _ := debugSymbolsLayer.Metadata[WorkspaceSHAKey].(string)
+			ok = previous == fingerprint
+		}
+
+		// The targets layer is only restored from the image as metadata, so the
+		// label has to come from there when the layer is reused
+		if ok && configuration.ProvenanceLabel {
+			_, ok = targetsLayer.Metadata[ProvenanceLabelKey].(string)
+		}
+
+		if ok {
+			logs.Process("Reusing cached layer %s", targetsLayer.Path)
+			logs.Break()
+		} else {
+			targetsLayer, err = targetsLayer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			targetsLayer.Launch = true
+
+			if configuration.SplitDebugSymbols {
+				debugSymbolsLayer, err = debugSymbolsLayer.Reset()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				debugSymbolsLayer.Cache = true
+				debugSymbolsLayer.Metadata = map[string]interface{}{
+					WorkspaceSHAKey: fingerprint,
+				}
+			}
+
+			goPath, path, err := pathManager.Setup(workingDir, configuration.ImportPath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			config := GoBuildConfiguration{
+				Workspace:           path,
+				PlatformPath:        context.Platform.Path,
+				Output:              filepath.Join(targetsLayer.Path, "bin"),
+				GoPath:              goPath,
+				GoCache:             goCacheLayer.Path,
+				GoModCache:          goModCacheLayer.Path,
+				Flags:               configuration.Flags,
+				Targets:             configuration.Targets,
+				TargetConfiguration: configuration.TargetConfiguration,
+				WorkspaceUseModules: configuration.WorkspaceUseModules,
+				Offline:             configuration.Offline,
+				Reproducible:        configuration.Reproducible,
+				VerifyReproducible:  configuration.VerifyReproducible,
+				Debug:               configuration.Debug,
+				PGO:                 profiles.Flag,
+				GeneratePatterns:    configuration.GeneratePatterns,
+				VetPatterns:         configuration.VetPatterns,
+				VetFlags:            configuration.VetFlags,
+				TestPatterns:        configuration.TestPatterns,
+				TestFlags:           configuration.TestFlags,
+				TestBinaryPatterns:  configuration.TestBinaryPatterns,
+				TestBinaryOutput:    filepath.Join(targetsLayer.Path, "tests"),
+			}
+
+			if configuration.SplitDebugSymbols {
+				config.DebugSymbolsOutput = debugSymbolsLayer.Path
+			}
+
+			if isStaticStack(context.Stack) && !containsFlag(config.Flags, "-buildmode") {
+				config.DisableCGO = true
+				config.Flags = append(config.Flags, "-buildmode", "default")
+			}
+
+			if configuration.WorkDir != "" {
+				logs.Process(fmt.Sprintf("Using BP_GO_WORKDIR variable, build subdirectory is '%s'", configuration.WorkDir))
+			}
+
+			var details BuildDetails
+			binaries, details, err = buildProcess.Execute(config)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			if len(profiles.Targets) > 0 {
+				logs.Process("Profile-guided optimization")
+				for _, target := range config.Targets {
+					profile, ok := profiles.Targets[target]
+					if !ok {
+						logs.Subprocess("%s: not applied", target)
+						continue
+					}
+
+					if rel, err := filepath.Rel(context.WorkingDir, profile); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+						profile = rel
+					}
+					logs.Subprocess("%s: applied %s", target, profile)
+				}
+				logs.Break()
+			}
+
+			if len(config.TestBinaryPatterns) > 0 {
+				testBinaries, err = buildProcess.CompileTests(config)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+
+			err = pathManager.Teardown(goPath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			if configuration.VulnCheck {
+				err = vulnerabilityScanner.Scan(VulnerabilityScanConfiguration{
+					Binaries:        append(slices.Clone(binaries), testBinaries...),
+					DebugSymbols:    details.DebugSymbols,
+					PlatformPath:    context.Platform.Path,
+					Database:        configuration.VulnCheckDatabase,
+					Allow:           configuration.VulnCheckAllow,
+					AllowSeverities: configuration.VulnCheckAllowSeverities,
+					Report:          filepath.Join(targetsLayer.Path, VulnerabilityReportFileName),
+				})
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+
+			if configuration.Licenses {
+				err = licenseCollector.Collect(LicenseCollectionConfiguration{
+					Binaries:   append(slices.Clone(binaries), testBinaries...),
+					Workspace:  workingDir,
+					Modules:    moduleDirs,
+					GoModCache: goModCacheLayer.Path,
+					Allow:      configuration.LicensesAllow,
+					Deny:       configuration.LicensesDeny,
+					Manifest:   filepath.Join(targetsLayer.Path, LicenseManifestFileName),
+				})
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+
+			// The whole layer is described so that the test binaries are
+			// included along with the binaries of the targets
+			logs.GeneratingSBOM(targetsLayer.Path)
+
+			var sbomContent sbom.SBOM
+			duration, err := clock.Measure(func() error {
+				sbomContent, err = sbomGenerator.Generate(targetsLayer.Path)
+				return err
+			})
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			logs.Action("Completed in %s", duration.Round(time.Millisecond))
+			logs.Break()
+
+			logs.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
+			targetsLayer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			targetsLayer.Metadata = map[string]interface{}{
+				WorkspaceSHAKey: fingerprint,
+				BinariesKey:     binaries,
+			}
+
+			if len(testBinaries) > 0 {
+				targetsLayer.Metadata[TestBinariesKey] = testBinaries
+			}
+
+			if config.Reproducible {
+				targetsLayer.Metadata[ReproducibilityKey] = reproducibilityRecord(config, details.Env, goVersion, context.Stack)
+			}
+
+			artifacts := append(slices.Clone(binaries), testBinaries...)
+			digests := map[string]string{}
+			for _, artifact := range artifacts {
+				digests[artifact], err = checksumCalculator.Sum(artifact)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+
+			project, _, err := readProjectMetadata(metadata.projectMetadataPath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			provenancePath := filepath.Join(targetsLayer.Path, ProvenanceFileName)
+			logs.Process("Writing provenance to %s", provenancePath)
+			logs.Break()
+
+			statement := newProvenanceStatement(artifacts, digests, configuration, details.Commands, provenanceSource{
+				workspaceSHA: workspaceSHA,
+				goVersion:    goVersion,
+				stack:        context.Stack,
+				project:      project,
+			}, context.BuildpackInfo)
+
+			err = writeProvenance(provenancePath, statement)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			if configuration.ProvenanceLabel {
+				targetsLayer.Metadata[ProvenanceLabelKey], err = provenanceLabel(statement)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+		}
+
+		var labels map[string]string
+		if configuration.ProvenanceLabel {
+			labels = map[string]string{ProvenanceLabel: targetsLayer.Metadata[ProvenanceLabelKey].(string)}
+		}
+
+		// The debugger shows the source that the binaries were built from, so it
+		// is kept in debug mode
+		if configuration.Debug {
+			logs.Process("Keeping the application source for debugging")
+			logs.Break()
+		} else {
+			err = sourceRemover.Clear(context.WorkingDir, configuration.KeepFiles)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		shouldReload, err := checkLiveReloadEnabled()
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		shouldReload, err := checkLiveReloadEnabled()
+		defaultIndex, err := findDefaultProcess(binaries, configuration.DefaultProcess)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
@@ -143,7 +455,7 @@ func Build(
 				Type:    filepath.Base(binary),
 				Command: binary,
 				Direct:  true,
-				Default: index == 0 && !shouldReload,
+				Default: index == defaultIndex && !shouldReload,
 			})
 
 			if shouldReload {
@@ -158,17 +470,37 @@ func Build(
 						"--",
 						binary},
 					Direct:  true,
-					Default: index == 0,
+					Default: index == defaultIndex,
 				})
 			}
+
+			if configuration.Debug {
+				processes = append(processes, debugProcess(binary, configuration.DebugPort))
+			}
+		}
+
+		// Test binaries are only ever run explicitly, so they are never the
+		// default process.
+		for _, binary := range testBinaries {
+			processes = append(processes, packit.Process{
+				Type:    fmt.Sprintf("test-%s", strings.TrimSuffix(filepath.Base(binary), ".test")),
+				Command: binary,
+				Direct:  true,
+			})
 		}
 
 		logs.LaunchProcesses(processes)
 
+		layers := []packit.Layer{targetsLayer, goCacheLayer, goModCacheLayer}
+		if configuration.SplitDebugSymbols {
+			layers = append(layers, debugSymbolsLayer)
+		}
+
 		return packit.BuildResult{
-			Layers: []packit.Layer{targetsLayer, goCacheLayer},
+			Layers: layers,
 			Launch: packit.LaunchMetadata{
 				Processes: processes,
+				Labels:    labels,
 			},
 		}, nil
 	}
@@ -177,3 +509,146 @@ func Build(
 func isStaticStack(stack string) bool {
 	return stack == JammyStaticStackID
 }
+
+func findDefaultProcess(binaries []string, name string) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+
+	for index, binary := range binaries {
+		if filepath.Base(binary) == name {
+			return index, nil
+		}
+	}
+
+	return 0, packit.Fail.WithMessage("default process %q does not match any of the built binaries", name)
+}
+
+// fingerprintInputs are the inputs that affect the compiled binaries and the
+// checks that are run on them. PGO profiles, service bindings and the
+// vulnerability database can live outside of the workspace, so their digests
+// are included when the build uses any.
+type fingerprintInputs struct {
+	WorkspaceSHA          string             `json:"workspace_sha"`
+	Profiles              string             `json:"profiles,omitempty"`
+	Bindings              string             `json:"bindings,omitempty"`
+	VulnerabilityDatabase string             `json:"vulnerability_database,omitempty"`
+	Environment           []string           `json:"environment,omitempty"`
+	Configuration         BuildConfiguration `json:"configuration"`
+	GoVersion             string             `json:"go_version"`
+	Stack                 string             `json:"stack"`
+}
+
+// calculateFingerprint combines every input that affects the compiled
+// binaries into a single digest so that the targets layer can be reused when
+// none of them have changed.
+func calculateFingerprint(inputs fingerprintInputs) (string, error) {
+	content, err := json.Marshal(inputs)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate build fingerprint: %w", err)
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// calculateBindingsSHA returns a checksum of the contents of the service
+// bindings that the go command builds with: the private module credentials
+// and, in offline builds, the module mirrors.
+func calculateBindingsSHA(resolver BindingResolver, checksumCalculator ChecksumCalculator, platformPath string, offline bool) (string, error) {
+	types := []string{GitCredentialsBindingType, NetrcBindingType, SSHBindingType}
+	if offline {
+		types = append(types, GoModuleMirrorBindingType)
+	}
+
+	var paths []string
+	for _, typ := range types {
+		bindings, err := resolver.Resolve(typ, "", platformPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s bindings: %w", typ, err)
+		}
+
+		for _, binding := range bindings {
+			paths = append(paths, binding.Path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	return checksumCalculator.Sum(paths...)
+}
+
+// calculateModuleSumsSHA returns a checksum of the module checksum files of
+// the workspace and of every module of the build, which is used to invalidate
+// the module cache whenever the dependencies of the application change.
+func calculateModuleSumsSHA(checksumCalculator ChecksumCalculator, workspace string, moduleDirs []string) (string, error) {
+	var paths []string
+	for _, path := range append([]string{filepath.Join(workspace, "go.work.sum")}, moduleSums(moduleDirs)...) {
+		exists, err := fs.Exists(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to check for %s: %w", filepath.Base(path), err)
+		}
+
+		if exists && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	return checksumCalculator.Sum(paths...)
+}
+
+func moduleSums(dirs []string) []string {
+	var paths []string
+	for _, dir := range dirs {
+		paths = append(paths, filepath.Join(dir, "go.sum"))
+	}
+
+	return paths
+}
+
+func cachedBinaries(layer packit.Layer, fingerprint string) ([]string, []string, bool) {
+	previous, ok := layer.Metadata[WorkspaceSHAKey].(string)
+	if !ok || previous != fingerprint {
+		return nil, nil, false
+	}
+
+	binaries, ok := metadataStrings(layer.Metadata[BinariesKey])
+	if !ok || len(binaries) == 0 {
+		return nil, nil, false
+	}
+
+	// The key is only present when test binaries were compiled
+	var testBinaries []string
+	if value, present := layer.Metadata[TestBinariesKey]; present {
+		testBinaries, ok = metadataStrings(value)
+		if !ok {
+			return nil, nil, false
+		}
+	}
+
+	return binaries, testBinaries, true
+}
+
+func metadataStrings(value interface{}) ([]string, bool) {
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	var strs []string
+	for _, entry := range entries {
+		str, ok := entry.(string)
+		if !ok {
+			return nil, false
+		}
+		strs = append(strs, str)
+	}
+
+	return strs, true
+}
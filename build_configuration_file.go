@@ -0,0 +1,260 @@
+package gobuild
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+const BuildConfigurationFileName = "go-build.toml"
+
+type buildConfigurationFile struct {
+	Targets                  []buildConfigurationFileTarget `toml:"targets"`
+	TargetsExclude           []string                       `toml:"targets-exclude"`
+	TargetsDepth             *int                           `toml:"targets-depth"`
+	Flags                    []string                       `toml:"flags"`
+	LDFlags                  string                         `toml:"ldflags"`
+	ImportPath               string                         `toml:"import-path"`
+	WorkUse                  []string                       `toml:"work-use"`
+	Offline                  bool                           `toml:"offline"`
+	Reproducible             bool                           `toml:"reproducible"`
+	VerifyReproducible       bool                           `toml:"verify-reproducible"`
+	ProvenanceLabel          bool                           `toml:"provenance-label"`
+	VulnCheck                bool                           `toml:"vulncheck"`
+	VulnCheckDB              string                         `toml:"vulncheck-db"`
+	VulnCheckAllow           []string                       `toml:"vulncheck-allow"`
+	VulnCheckAllowSeverities []string                       `toml:"vulncheck-allow-severities"`
+	Licenses                 bool                           `toml:"licenses"`
+	LicensesAllow            []string                       `toml:"licenses-allow"`
+	LicensesDeny             []string                       `toml:"licenses-deny"`
+	Debug                    bool                           `toml:"debug"`
+	DebugPort                *int                           `toml:"debug-port"`
+	SplitDebugSymbols        bool                           `toml:"split-debug-symbols"`
+	PGO                      string                         `toml:"pgo"`
+	KeepFiles                []string                       `toml:"keep-files"`
+	Generate                 packagePatterns                `toml:"generate"`
+	Vet                      packagePatterns                `toml:"vet"`
+	VetFlags                 []string                       `toml:"vet-flags"`
+	Test                     packagePatterns                `toml:"test"`
+	TestFlags                []string                       `toml:"test-flags"`
+	TestBinaries             packagePatterns                `toml:"test-binaries"`
+	Process                  struct {
+		Default string `toml:"default"`
+	} `toml:"process"`
+	Modules struct {
+		Deny                []string `toml:"deny"`
+		DenyPseudoVersions  []string `toml:"deny-pseudo-versions"`
+		DenyExternalReplace bool     `toml:"deny-external-replace"`
+		RequireToolchain    bool     `toml:"require-toolchain"`
+	} `toml:"modules"`
+}
+
+// buildConfigurationFileTarget is an entry in the targets list of the
+// configuration file. It is either a plain path or a table that carries
+// settings that only apply to that target:
+//
+//	targets = [
+//	  "./cmd/worker",
+//	  { path = "./cmd/server", name = "api", tags = ["netgo"], cgo = false },
+//	]
+type buildConfigurationFileTarget struct {
+	Path      string
+	Name      string
+	Flags     []string
+	Tags      []string
+	LDFlags   string
+	BuildMode string
+	CGO       *bool
+}
+
+func (t *buildConfigurationFileTarget) UnmarshalTOML(data interface{}) error {
+	switch value := data.(type) {
+	case string:
+		t.Path = value
+		return nil
+
+	case map[string]interface{}:
+		var keys []string
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var err error
+		for _, key := range keys {
+			switch key {
+			case "path":
+				t.Path, err = tomlString(key, value[key])
+			case "name":
+				t.Name, err = tomlString(key, value[key])
+			case "flags":
+				t.Flags, err = tomlStrings(key, value[key])
+			case "tags":
+				t.Tags, err = tomlStrings(key, value[key])
+			case "ldflags":
+				t.LDFlags, err = tomlString(key, value[key])
+			case "buildmode":
+				t.BuildMode, err = tomlString(key, value[key])
+			case "cgo":
+				cgo, ok := value[key].(bool)
+				if !ok {
+					err = fmt.Errorf("target key %q must be a boolean", key)
+				}
+				t.CGO = &cgo
+			default:
+				err = fmt.Errorf("unknown target key %q", key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if t.Path == "" {
+			return fmt.Errorf("target is missing a path")
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("target must be a string or a table, got %T", data)
+	}
+}
+
+// Configuration returns the flags and environment that should be used when
+// compiling this target, or false when the target has no specific settings.
+// The binary name is handled separately as it can also be set through
+// BP_GO_TARGETS.
+func (t buildConfigurationFileTarget) Configuration() (TargetConfiguration, bool) {
+	var configuration TargetConfiguration
+	configuration.Flags = append(configuration.Flags, t.Flags...)
+
+	if len(t.Tags) > 0 {
+		configuration.Flags = append(configuration.Flags, fmt.Sprintf("-tags=%s", strings.Join(t.Tags, ",")))
+	}
+
+	if t.LDFlags != "" {
+		configuration.Flags = append(configuration.Flags, fmt.Sprintf("-ldflags=%s", t.LDFlags))
+	}
+
+	if t.BuildMode != "" {
+		configuration.Flags = append(configuration.Flags, fmt.Sprintf("-buildmode=%s", t.BuildMode))
+	}
+
+	if t.CGO != nil {
+		if *t.CGO {
+			configuration.Env = append(configuration.Env, "CGO_ENABLED=1")
+		} else {
+			configuration.Env = append(configuration.Env, "CGO_ENABLED=0")
+		}
+	}
+
+	return configuration, len(configuration.Flags) > 0 || len(configuration.Env) > 0
+}
+
+// packagePatterns holds the packages that a go command such as 'go generate'
+// or 'go test' runs on. In the configuration file it is either a boolean,
+// which selects every package, or a list of package patterns.
+type packagePatterns []string
+
+func (p *packagePatterns) UnmarshalTOML(data interface{}) error {
+	switch value := data.(type) {
+	case bool:
+		*p = nil
+		if value {
+			*p = packagePatterns{defaultPackagePattern}
+		}
+
+		return nil
+
+	case []interface{}:
+		*p = nil
+		for _, v := range value {
+			pattern, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("must be a boolean or a list of package patterns")
+			}
+			*p = append(*p, pattern)
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("must be a boolean or a list of package patterns")
+	}
+}
+
+func tomlString(key string, value interface{}) (string, error) {
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("target key %q must be a string", key)
+	}
+
+	return str, nil
+}
+
+func tomlStrings(key string, value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("target key %q must be a list of strings", key)
+	}
+
+	var strs []string
+	for _, element := range list {
+		str, ok := element.(string)
+		if !ok {
+			return nil, fmt.Errorf("target key %q must be a list of strings", key)
+		}
+		strs = append(strs, str)
+	}
+
+	return strs, nil
+}
+
+// findBuildConfigurationFile returns the path of the go-build.toml that
+// applies to the build. A file in the build working directory takes
+// precedence over one in the application root.
+func findBuildConfigurationFile(workingDir, workDir string) (string, bool, error) {
+	var dirs []string
+	if workDir != "" {
+		dirs = append(dirs, filepath.Join(workingDir, workDir))
+	}
+	dirs = append(dirs, workingDir)
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, BuildConfigurationFileName)
+		exists, err := fs.Exists(path)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to check for %s: %w", BuildConfigurationFileName, err)
+		}
+
+		if exists {
+			return path, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+func parseBuildConfigurationFile(path string) (buildConfigurationFile, error) {
+	var file buildConfigurationFile
+	metadata, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return buildConfigurationFile{}, fmt.Errorf("failed to parse %s: %w", BuildConfigurationFileName, err)
+	}
+
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		var keys []string
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+
+		return buildConfigurationFile{}, fmt.Errorf("failed to parse %s: unknown keys: %s", BuildConfigurationFileName, strings.Join(keys, ", "))
+	}
+
+	return file, nil
+}
@@ -1,9 +1,11 @@
 package gobuild
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/mattn/go-shellwords"
@@ -13,15 +15,59 @@ import (
 //go:generate faux --interface TargetManager --output fakes/target_manager.go
 type TargetManager interface {
 	CleanAndValidate(targets []string, workingDir string) ([]string, error)
-	GenerateDefaults(workingDir string) ([]string, error)
+	GenerateDefaults(workingDir string, depth int) ([]string, error)
 }
 
+// defaultPackagePattern is the package pattern that 'go generate', 'go vet'
+// and 'go test' run on when they are enabled with a value of true.
+const defaultPackagePattern = "./..."
+
+// defaultTargetsDepth is how many directories below the working directory are
+// searched for main packages when no targets are given.
+const defaultTargetsDepth = 3
+
 type BuildConfiguration struct {
-	Targets             []string
-	Flags               []string
-	ImportPath          string
-	WorkspaceUseModules []string
-	WorkDir             string
+	Targets                    []string
+	TargetConfiguration        map[string]TargetConfiguration
+	Flags                      []string
+	ImportPath                 string
+	WorkspaceUseModules        []string
+	Offline                    bool
+	Reproducible               bool
+	VerifyReproducible         bool
+	ProvenanceLabel            bool
+	VulnCheck                  bool
+	VulnCheckDatabase          string
+	VulnCheckAllow             []string
+	VulnCheckAllowSeverities   []string
+	Licenses                   bool
+	LicensesAllow              []string
+	LicensesDeny               []string
+	ModulesDeny                []string
+	ModulesDenyPseudoVersions  []string
+	ModulesDenyExternalReplace bool
+	ModulesRequireToolchain    bool
+	Debug                      bool
+	DebugPort                  int
+	SplitDebugSymbols          bool
+	PGO                        string
+	WorkDir                    string
+	KeepFiles                  []string
+	DefaultProcess             string
+	GeneratePatterns           []string
+	VetPatterns                []string
+	VetFlags                   []string
+	TestPatterns               []string
+	TestFlags                  []string
+	TestBinaryPatterns         []string
+}
+
+// TargetConfiguration holds the build settings that apply to a single target
+// on top of the flags that apply to every target.
+type TargetConfiguration struct {
+	Name  string
+	Flags []string
+	Env   []string
 }
 
 type BuildConfigurationParser struct {
@@ -40,16 +86,15 @@ func (p BuildConfigurationParser) Parse(buildpackVersion, workingDir string) (Bu
 		return BuildConfiguration{}, fmt.Errorf("failed to check for buildpack.yml: %w", err)
 	}
 	if bpYML {
-		return BuildConfiguration{}, fmt.Errorf("working directory contains deprecated 'buildpack.yml'; use environment variables for configuration")
+		return BuildConfiguration{}, fmt.Errorf("working directory contains deprecated 'buildpack.yml'; use environment variables or %s for configuration", BuildConfigurationFileName)
 	}
 
 	var buildConfiguration BuildConfiguration
-	if val, ok := os.LookupEnv("BP_GO_TARGETS"); ok {
-		buildConfiguration.Targets = filepath.SplitList(val)
-	}
-
+	root := workingDir
+	workspace := workingDir
 	if val, ok := os.LookupEnv("BP_GO_WORKDIR"); ok {
 		buildConfiguration.WorkDir = val
+		workspace = filepath.Join(workingDir, val)
 
 		// Validate that the work directory exists
 		workDirPath := filepath.Join(workingDir, val)
@@ -59,37 +104,427 @@ func (p BuildConfigurationParser) Parse(buildpackVersion, workingDir string) (Bu
 			return BuildConfiguration{}, fmt.Errorf("BP_GO_WORKDIR path '%s' is not a directory", val)
 		}
 	}
+
+	var file buildConfigurationFile
+	path, ok, err := findBuildConfigurationFile(workingDir, buildConfiguration.WorkDir)
+	if err != nil {
+		return BuildConfiguration{}, err
+	}
+
+	if ok {
+		file, err = parseBuildConfigurationFile(path)
+		if err != nil {
+			return BuildConfiguration{}, err
+		}
+	}
+
+	// Values set through environment variables take precedence over the values
+	// in the configuration file.
+	names := map[string]string{}
+	for _, target := range file.Targets {
+		buildConfiguration.Targets = append(buildConfiguration.Targets, target.Path)
+		if target.Name != "" {
+			if isTargetPattern(target.Path) {
+				return BuildConfiguration{}, fmt.Errorf("binary name %q cannot be given to the package pattern %q", target.Name, target.Path)
+			}
+			names[cleanTarget(target.Path)] = target.Name
+		}
+	}
+
+	if val, ok := os.LookupEnv("BP_GO_TARGETS"); ok {
+		buildConfiguration.Targets = nil
+		for _, entry := range filepath.SplitList(val) {
+			// Targets can be given an explicit binary name using the name=path
+			// syntax, e.g. BP_GO_TARGETS=api=./cmd/server
+			target := entry
+			if name, path, ok := strings.Cut(entry, "="); ok {
+				if isTargetPattern(path) {
+					return BuildConfiguration{}, fmt.Errorf("binary name %q cannot be given to the package pattern %q", name, path)
+				}
+				target = path
+				names[cleanTarget(path)] = name
+			}
+
+			buildConfiguration.Targets = append(buildConfiguration.Targets, target)
+		}
+	}
+
 	if len(buildConfiguration.Targets) > 0 {
 		buildConfiguration.Targets, err = p.targetManager.CleanAndValidate(buildConfiguration.Targets, workingDir)
 		if err != nil {
 			return BuildConfiguration{}, err
 		}
 	} else {
+		depth := defaultTargetsDepth
+		if file.TargetsDepth != nil {
+			depth = *file.TargetsDepth
+		}
+
+		if val, ok := os.LookupEnv("BP_GO_TARGETS_DEPTH"); ok {
+			depth, err = strconv.Atoi(val)
+			if err != nil {
+				return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_TARGETS_DEPTH: %w", err)
+			}
+		}
+
+		if depth < 0 {
+			return BuildConfiguration{}, fmt.Errorf("targets depth must not be negative, got %d", depth)
+		}
+
 		if buildConfiguration.WorkDir != "" {
 			workingDir = buildConfiguration.WorkDir
 		}
-		buildConfiguration.Targets, err = p.targetManager.GenerateDefaults(workingDir)
+		buildConfiguration.Targets, err = p.targetManager.GenerateDefaults(workingDir, depth)
 		if err != nil {
 			return BuildConfiguration{}, err
 		}
 	}
 
+	excludes := file.TargetsExclude
+	if val, ok := os.LookupEnv("BP_GO_TARGETS_EXCLUDE"); ok {
+		excludes = filepath.SplitList(val)
+	}
+
+	if len(excludes) > 0 {
+		buildConfiguration.Targets = excludeTargets(buildConfiguration.Targets, excludes)
+		if len(buildConfiguration.Targets) == 0 {
+			return BuildConfiguration{}, fmt.Errorf("failed to determine build targets: all targets were excluded by %q", strings.Join(excludes, string(filepath.ListSeparator)))
+		}
+	}
+
+	// Settings for individual targets are matched by path so that they still
+	// apply when the list of targets is overridden by BP_GO_TARGETS. Settings
+	// given for a package pattern apply to every target matching it, with later
+	// entries taking precedence.
+	for _, target := range file.Targets {
+		configuration, ok := target.Configuration()
+		if !ok {
+			continue
+		}
+
+		match := matchTargetPattern(target.Path)
+		for _, t := range buildConfiguration.Targets {
+			if match(t) {
+				if buildConfiguration.TargetConfiguration == nil {
+					buildConfiguration.TargetConfiguration = map[string]TargetConfiguration{}
+				}
+				buildConfiguration.TargetConfiguration[t] = configuration
+			}
+		}
+	}
+
+	owners := map[string]string{}
+	for _, target := range buildConfiguration.Targets {
+		name, ok := names[target]
+		if !ok {
+			continue
+		}
+
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return BuildConfiguration{}, fmt.Errorf("invalid binary name %q for target %q", name, target)
+		}
+
+		if owner, ok := owners[name]; ok {
+			return BuildConfiguration{}, fmt.Errorf("targets %q and %q are both named %q", owner, target, name)
+		}
+		owners[name] = target
+
+		if buildConfiguration.TargetConfiguration == nil {
+			buildConfiguration.TargetConfiguration = map[string]TargetConfiguration{}
+		}
+
+		configuration := buildConfiguration.TargetConfiguration[target]
+		configuration.Name = name
+		buildConfiguration.TargetConfiguration[target] = configuration
+	}
+
+	buildConfiguration.Flags = file.Flags
+	if file.LDFlags != "" {
+		buildConfiguration.Flags = setLDFlags(buildConfiguration.Flags, fmt.Sprintf("-ldflags=%s", file.LDFlags))
+	}
+
 	buildConfiguration.Flags, err = parseFlagsFromEnvVars(buildConfiguration.Flags)
 	if err != nil {
 		return BuildConfiguration{}, err
 	}
 
+	buildConfiguration.ImportPath = file.ImportPath
 	if val, ok := os.LookupEnv("BP_GO_BUILD_IMPORT_PATH"); ok {
 		buildConfiguration.ImportPath = val
 	}
 
+	buildConfiguration.WorkspaceUseModules = file.WorkUse
 	if val, ok := os.LookupEnv("BP_GO_WORK_USE"); ok {
 		buildConfiguration.WorkspaceUseModules = filepath.SplitList(val)
 	}
 
+	buildConfiguration.WorkspaceUseModules, err = resolveWorkspaceModules(workspace, buildConfiguration.WorkspaceUseModules)
+	if err != nil {
+		return BuildConfiguration{}, err
+	}
+
+	buildConfiguration.Offline = file.Offline
+	if val, ok := os.LookupEnv("BP_GO_OFFLINE"); ok {
+		buildConfiguration.Offline, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_OFFLINE: %w", err)
+		}
+	}
+
+	buildConfiguration.Reproducible = file.Reproducible
+	if val, ok := os.LookupEnv("BP_GO_REPRODUCIBLE"); ok {
+		buildConfiguration.Reproducible, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_REPRODUCIBLE: %w", err)
+		}
+	}
+
+	buildConfiguration.VerifyReproducible = file.VerifyReproducible
+	if val, ok := os.LookupEnv("BP_GO_VERIFY_REPRODUCIBLE"); ok {
+		buildConfiguration.VerifyReproducible, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_VERIFY_REPRODUCIBLE: %w", err)
+		}
+	}
+
+	buildConfiguration.ProvenanceLabel = file.ProvenanceLabel
+	if val, ok := os.LookupEnv("BP_GO_PROVENANCE_LABEL"); ok {
+		buildConfiguration.ProvenanceLabel, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_PROVENANCE_LABEL: %w", err)
+		}
+	}
+
+	buildConfiguration.VulnCheck = file.VulnCheck
+	if val, ok := os.LookupEnv("BP_GO_VULNCHECK"); ok {
+		buildConfiguration.VulnCheck, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_VULNCHECK: %w", err)
+		}
+	}
+
+	buildConfiguration.VulnCheckDatabase = file.VulnCheckDB
+	if val, ok := os.LookupEnv("BP_GO_VULNCHECK_DB"); ok {
+		buildConfiguration.VulnCheckDatabase = val
+	}
+
+	// Relative database paths are given from the root of the application
+	if buildConfiguration.VulnCheckDatabase != "" && !filepath.IsAbs(buildConfiguration.VulnCheckDatabase) {
+		buildConfiguration.VulnCheckDatabase = filepath.Join(root, buildConfiguration.VulnCheckDatabase)
+	}
+
+	buildConfiguration.VulnCheckAllow = file.VulnCheckAllow
+	if val, ok := os.LookupEnv("BP_GO_VULNCHECK_ALLOW"); ok {
+		buildConfiguration.VulnCheckAllow = filepath.SplitList(val)
+	}
+
+	buildConfiguration.VulnCheckAllowSeverities = file.VulnCheckAllowSeverities
+	if val, ok := os.LookupEnv("BP_GO_VULNCHECK_ALLOW_SEVERITIES"); ok {
+		buildConfiguration.VulnCheckAllowSeverities = filepath.SplitList(val)
+	}
+
+	buildConfiguration.Licenses = file.Licenses
+	if val, ok := os.LookupEnv("BP_GO_LICENSES"); ok {
+		buildConfiguration.Licenses, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_LICENSES: %w", err)
+		}
+	}
+
+	buildConfiguration.LicensesAllow = file.LicensesAllow
+	if val, ok := os.LookupEnv("BP_GO_LICENSES_ALLOW"); ok {
+		buildConfiguration.LicensesAllow = filepath.SplitList(val)
+	}
+
+	buildConfiguration.LicensesDeny = file.LicensesDeny
+	if val, ok := os.LookupEnv("BP_GO_LICENSES_DENY"); ok {
+		buildConfiguration.LicensesDeny = filepath.SplitList(val)
+	}
+
+	buildConfiguration.ModulesDeny = file.Modules.Deny
+	if val, ok := os.LookupEnv("BP_GO_MODULES_DENY"); ok {
+		buildConfiguration.ModulesDeny = filepath.SplitList(val)
+	}
+
+	err = validateModulePatterns("BP_GO_MODULES_DENY", buildConfiguration.ModulesDeny)
+	if err != nil {
+		return BuildConfiguration{}, err
+	}
+
+	buildConfiguration.ModulesDenyPseudoVersions = file.Modules.DenyPseudoVersions
+	if val, ok := os.LookupEnv("BP_GO_MODULES_DENY_PSEUDO_VERSIONS"); ok {
+		buildConfiguration.ModulesDenyPseudoVersions = filepath.SplitList(val)
+	}
+
+	err = validateModulePatterns("BP_GO_MODULES_DENY_PSEUDO_VERSIONS", buildConfiguration.ModulesDenyPseudoVersions)
+	if err != nil {
+		return BuildConfiguration{}, err
+	}
+
+	buildConfiguration.ModulesDenyExternalReplace = file.Modules.DenyExternalReplace
+	if val, ok := os.LookupEnv("BP_GO_MODULES_DENY_EXTERNAL_REPLACE"); ok {
+		buildConfiguration.ModulesDenyExternalReplace, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_MODULES_DENY_EXTERNAL_REPLACE: %w", err)
+		}
+	}
+
+	buildConfiguration.ModulesRequireToolchain = file.Modules.RequireToolchain
+	if val, ok := os.LookupEnv("BP_GO_MODULES_REQUIRE_TOOLCHAIN"); ok {
+		buildConfiguration.ModulesRequireToolchain, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_MODULES_REQUIRE_TOOLCHAIN: %w", err)
+		}
+	}
+
+	buildConfiguration.Debug = file.Debug
+	if val, ok := os.LookupEnv("BP_GO_DEBUG"); ok {
+		buildConfiguration.Debug, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_DEBUG: %w", err)
+		}
+	}
+
+	if buildConfiguration.Debug {
+		buildConfiguration.DebugPort = defaultDebugPort
+		if file.DebugPort != nil {
+			buildConfiguration.DebugPort = *file.DebugPort
+		}
+
+		if val, ok := os.LookupEnv("BP_GO_DEBUG_PORT"); ok {
+			buildConfiguration.DebugPort, err = strconv.Atoi(val)
+			if err != nil {
+				return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_DEBUG_PORT: %w", err)
+			}
+		}
+
+		if buildConfiguration.DebugPort < 1 || buildConfiguration.DebugPort > 65535 {
+			return BuildConfiguration{}, fmt.Errorf("debug port must be between 1 and 65535, got %d", buildConfiguration.DebugPort)
+		}
+	}
+
+	buildConfiguration.SplitDebugSymbols = file.SplitDebugSymbols
+	if val, ok := os.LookupEnv("BP_GO_SPLIT_DEBUG_SYMBOLS"); ok {
+		buildConfiguration.SplitDebugSymbols, err = strconv.ParseBool(val)
+		if err != nil {
+			return BuildConfiguration{}, fmt.Errorf("failed to parse BP_GO_SPLIT_DEBUG_SYMBOLS: %w", err)
+		}
+	}
+
+	if buildConfiguration.Debug && buildConfiguration.SplitDebugSymbols {
+		return BuildConfiguration{}, errors.New("BP_GO_DEBUG cannot be combined with BP_GO_SPLIT_DEBUG_SYMBOLS, debug mode keeps the debug symbols in the binaries")
+	}
+
+	buildConfiguration.PGO = file.PGO
+	if val, ok := os.LookupEnv("BP_GO_PGO"); ok {
+		buildConfiguration.PGO = val
+	}
+
+	// Relative profile paths are given from the root of the application
+	if buildConfiguration.PGO != "" && buildConfiguration.PGO != PGOOff && !filepath.IsAbs(buildConfiguration.PGO) {
+		buildConfiguration.PGO = filepath.Join(root, buildConfiguration.PGO)
+	}
+
+	buildConfiguration.KeepFiles = file.KeepFiles
+	if val, ok := os.LookupEnv("BP_KEEP_FILES"); ok {
+		buildConfiguration.KeepFiles = filepath.SplitList(val)
+	}
+
+	buildConfiguration.DefaultProcess = file.Process.Default
+
+	buildConfiguration.GeneratePatterns = file.Generate
+	if val, ok := os.LookupEnv("BP_GO_GENERATE"); ok {
+		buildConfiguration.GeneratePatterns = parsePackagePatterns(val)
+	}
+
+	buildConfiguration.VetPatterns = file.Vet
+	if val, ok := os.LookupEnv("BP_GO_VET"); ok {
+		buildConfiguration.VetPatterns = parsePackagePatterns(val)
+	}
+
+	buildConfiguration.VetFlags, err = parseFlagsFromEnvVar("BP_GO_VET_FLAGS", file.VetFlags)
+	if err != nil {
+		return BuildConfiguration{}, err
+	}
+
+	buildConfiguration.TestPatterns = file.Test
+	if val, ok := os.LookupEnv("BP_GO_TEST"); ok {
+		buildConfiguration.TestPatterns = parsePackagePatterns(val)
+	}
+
+	buildConfiguration.TestFlags, err = parseFlagsFromEnvVar("BP_GO_TEST_FLAGS", file.TestFlags)
+	if err != nil {
+		return BuildConfiguration{}, err
+	}
+
+	buildConfiguration.TestBinaryPatterns = file.TestBinaries
+	if val, ok := os.LookupEnv("BP_GO_TEST_BINARIES"); ok {
+		buildConfiguration.TestBinaryPatterns = parsePackagePatterns(val)
+	}
+
 	return buildConfiguration, nil
 }
 
+// parsePackagePatterns interprets the value of variables such as
+// BP_GO_GENERATE, which are either a boolean or a list of package patterns.
+func parsePackagePatterns(val string) []string {
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return filepath.SplitList(val)
+	}
+
+	if enabled {
+		return []string{defaultPackagePattern}
+	}
+
+	return nil
+}
+
+// parseFlagsFromEnvVar returns the flags in the given environment variable,
+// or the fallback flags when it is not set.
+func parseFlagsFromEnvVar(name string, fallback []string) ([]string, error) {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return fallback, nil
+	}
+
+	shellwordsParser := shellwords.NewParser()
+	shellwordsParser.ParseEnv = true
+
+	flags, err := shellwordsParser.Parse(val)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	return flags, nil
+}
+
+// excludeTargets returns the targets that do not match any of the exclusion
+// patterns.
+func excludeTargets(targets, excludes []string) []string {
+	var matchers []func(string) bool
+	for _, exclude := range excludes {
+		matchers = append(matchers, matchTargetPattern(exclude))
+	}
+
+	var remaining []string
+	for _, target := range targets {
+		excluded := false
+		for _, match := range matchers {
+			if match(target) {
+				excluded = true
+				break
+			}
+		}
+
+		if !excluded {
+			remaining = append(remaining, target)
+		}
+	}
+
+	return remaining
+}
+
 func containsFlag(flags []string, match string) bool {
 	for _, flag := range flags {
 		if strings.HasPrefix(flag, match) {
@@ -120,17 +555,23 @@ func parseFlagsFromEnvVars(flags []string) ([]string, error) {
 			return nil, fmt.Errorf("BP_GO_BUILD_LDFLAGS value (%s) could not be parsed: value contains multiple words", ldFlags)
 		}
 
-		for i, flag := range flags {
-			if strings.HasPrefix(flag, "-ldflags") {
-				// Replace value from BP_GO_BUILD_FLAGS or buildpack.yml with value from
-				// BP_GO_BUILD_LDFLAGS because BP_GO_BUILD_LDFLAGS takes precedence
-				flags[i] = parsed[0]
-			}
-		}
+		// Replace value from BP_GO_BUILD_FLAGS or go-build.toml with value from
+		// BP_GO_BUILD_LDFLAGS because BP_GO_BUILD_LDFLAGS takes precedence
+		flags = setLDFlags(flags, parsed[0])
+	}
+	return flags, nil
+}
 
-		if !containsFlag(flags, "-ldflags") {
-			flags = append(flags, parsed[0])
+func setLDFlags(flags []string, ldFlags string) []string {
+	for i, flag := range flags {
+		if strings.HasPrefix(flag, "-ldflags") {
+			flags[i] = ldFlags
 		}
 	}
-	return flags, nil
+
+	if !containsFlag(flags, "-ldflags") {
+		flags = append(flags, ldFlags)
+	}
+
+	return flags
 }
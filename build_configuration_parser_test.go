@@ -57,6 +57,70 @@ func testBuildConfigurationParser(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when BP_GO_TARGETS contains named targets", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_TARGETS", "api=some/target1:./some/target2")
+			targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./some/target1", "./some/target2"}
+		})
+
+		it("returns the binary name for those targets", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration).To(Equal(gobuild.BuildConfiguration{
+				Targets: []string{"./some/target1", "./some/target2"},
+				TargetConfiguration: map[string]gobuild.TargetConfiguration{
+					"./some/target1": {Name: "api"},
+				},
+			}))
+
+			Expect(targetManager.CleanAndValidateCall.Receives.Targets).To(Equal([]string{"some/target1", "./some/target2"}))
+		})
+	})
+
+	context("when a named target is the working directory", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_TARGETS", "api=./.")
+			targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"."}
+		})
+
+		it("returns the binary name for the target", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.TargetConfiguration).To(Equal(map[string]gobuild.TargetConfiguration{
+				".": {Name: "api"},
+			}))
+		})
+	})
+
+	context("when BP_GO_TARGETS_EXCLUDE is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_TARGETS", "./cmd/...")
+			t.Setenv("BP_GO_TARGETS_EXCLUDE", "./cmd/tools/...:cmd/example")
+			targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./cmd/example", "./cmd/server", "./cmd/tools", "./cmd/tools/gen"}
+		})
+
+		it("removes the matching targets", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.Targets).To(Equal([]string{"./cmd/server"}))
+
+			Expect(targetManager.CleanAndValidateCall.Receives.Targets).To(Equal([]string{"./cmd/..."}))
+		})
+
+		context("when the targets are generated", func() {
+			it.Before(func() {
+				Expect(os.Unsetenv("BP_GO_TARGETS")).To(Succeed())
+				targetManager.GenerateDefaultsCall.Returns.StringSlice = []string{"./cmd/example", "./cmd/server"}
+			})
+
+			it("removes the matching targets", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.Targets).To(Equal([]string{"./cmd/server"}))
+			})
+		})
+	})
+
 	context("when BP_GO_BUILD_FLAGS is set", func() {
 		it.Before(func() {
 			t.Setenv("BP_GO_BUILD_FLAGS", `-buildmode=default -tags=paketo -ldflags="-X main.variable=some-value" -first=$FIRST -second=${SECOND}`)
@@ -79,6 +143,7 @@ func testBuildConfigurationParser(t *testing.T, context spec.G, it spec.S) {
 			}))
 
 			Expect(targetManager.GenerateDefaultsCall.Receives.WorkingDir).To(Equal(workingDir))
+			Expect(targetManager.GenerateDefaultsCall.Receives.Depth).To(Equal(3))
 		})
 	})
 
@@ -169,6 +234,11 @@ func testBuildConfigurationParser(t *testing.T, context spec.G, it spec.S) {
 	context("when BP_GO_WORK_USE is set", func() {
 		it.Before(func() {
 			t.Setenv("BP_GO_WORK_USE", "./some/module1:./some/module2")
+
+			for _, module := range []string{"module1", "module2"} {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "some", module), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "some", module, "go.mod"), nil, 0600)).To(Succeed())
+			}
 		})
 
 		it("uses the values in the env var", func() {
@@ -181,6 +251,81 @@ func testBuildConfigurationParser(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(targetManager.GenerateDefaultsCall.Receives.WorkingDir).To(Equal(workingDir))
 		})
+
+		context("when one of the modules does not exist", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_WORK_USE", "./some/module1:./some/module3")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(`failed to validate workspace module "./some/module3": directory does not exist`))
+			})
+		})
+
+		context("when one of the modules is a file", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_WORK_USE", "./some/module1/go.mod")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(`failed to validate workspace module "./some/module1/go.mod": not a directory`))
+			})
+		})
+
+		context("when one of the modules does not contain a go.mod", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "some", "package"), os.ModePerm)).To(Succeed())
+				t.Setenv("BP_GO_WORK_USE", "./some/package")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(`failed to validate workspace module "./some/package": directory does not contain a go.mod file`))
+			})
+		})
+
+		context("when BP_GO_WORKDIR is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_WORKDIR", "some")
+				t.Setenv("BP_GO_WORK_USE", "./module1:./module2")
+			})
+
+			it("validates the modules relative to the work directory", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.WorkspaceUseModules).To(Equal([]string{"./module1", "./module2"}))
+			})
+		})
+
+		context("when the value is auto", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_WORK_USE", "auto")
+
+				for _, path := range []string{"go.mod", "tools/nested/go.mod", "vendor/example.com/dep/go.mod", "testdata/fixture/go.mod", ".hidden/go.mod"} {
+					Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, path), nil, 0600)).To(Succeed())
+				}
+			})
+
+			it("uses every module in the working directory", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.WorkspaceUseModules).To(Equal([]string{".", "./some/module1", "./some/module2", "./tools/nested"}))
+			})
+		})
+	})
+
+	context("when BP_GO_WORK_USE is auto and there are no modules", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_WORK_USE", "auto")
+		})
+
+		it("returns an error", func() {
+			_, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).To(MatchError("failed to discover workspace modules: no go.mod files could be found"))
+		})
 	})
 
 	context("when BP_GO_WORKDIR is set", func() {
@@ -226,14 +371,1237 @@ func testBuildConfigurationParser(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
-	context("failure cases", func() {
+	context("when BP_GO_TARGETS_DEPTH is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_TARGETS_DEPTH", "5")
+		})
+
+		it("searches for targets up to that depth", func() {
+			_, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(targetManager.GenerateDefaultsCall.Receives.Depth).To(Equal(5))
+		})
+
+		context("when the go-build.toml also sets a depth", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte("targets-depth = 1"), 0600)).To(Succeed())
+			})
+
+			it("gives the environment variable precedence", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(targetManager.GenerateDefaultsCall.Receives.Depth).To(Equal(5))
+			})
+		})
+	})
+
+	context("when the go-build.toml sets a depth", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte("targets-depth = 1"), 0600)).To(Succeed())
+		})
+
+		it("searches for targets up to that depth", func() {
+			_, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(targetManager.GenerateDefaultsCall.Receives.Depth).To(Equal(1))
+		})
+	})
+
+	context("when BP_GO_GENERATE is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_GENERATE", "true")
+		})
+
+		it("runs go generate on every package", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.GeneratePatterns).To(Equal([]string{"./..."}))
+		})
+
+		context("when it is set to false", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_GENERATE", "false")
+				Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte("generate = true"), 0600)).To(Succeed())
+			})
+
+			it("does not run go generate", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.GeneratePatterns).To(BeEmpty())
+			})
+		})
+
+		context("when it is set to package patterns", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_GENERATE", "./api/...:./internal/enums")
+			})
+
+			it("runs go generate on those packages", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.GeneratePatterns).To(Equal([]string{"./api/...", "./internal/enums"}))
+			})
+		})
+	})
+
+	context("when the go-build.toml enables go generate", func() {
+		it("runs go generate on every package", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte("generate = true"), 0600)).To(Succeed())
+
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.GeneratePatterns).To(Equal([]string{"./..."}))
+		})
+
+		it("runs go generate on the listed packages", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`generate = ["./api/..."]`), 0600)).To(Succeed())
+
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.GeneratePatterns).To(Equal([]string{"./api/..."}))
+		})
+	})
+
+	context("when BP_GO_VET and BP_GO_TEST are set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_VET", "true")
+			t.Setenv("BP_GO_VET_FLAGS", "-printf=false")
+			t.Setenv("BP_GO_TEST", "./internal/...:./pkg/...")
+			t.Setenv("BP_GO_TEST_FLAGS", `-short -run "Unit|Integration"`)
+		})
+
+		it("uses the values in the env vars", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.VetPatterns).To(Equal([]string{"./..."}))
+			Expect(configuration.VetFlags).To(Equal([]string{"-printf=false"}))
+			Expect(configuration.TestPatterns).To(Equal([]string{"./internal/...", "./pkg/..."}))
+			Expect(configuration.TestFlags).To(Equal([]string{"-short", "-run", "Unit|Integration"}))
+		})
+	})
+
+	context("when the go-build.toml enables go vet and go test", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`
+vet = ["./cmd/..."]
+vet-flags = ["-printf=false"]
+test = true
+test-flags = ["-race"]
+`), 0600)).To(Succeed())
+		})
+
+		it("uses the values in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.VetPatterns).To(Equal([]string{"./cmd/..."}))
+			Expect(configuration.VetFlags).To(Equal([]string{"-printf=false"}))
+			Expect(configuration.TestPatterns).To(Equal([]string{"./..."}))
+			Expect(configuration.TestFlags).To(Equal([]string{"-race"}))
+		})
+
+		context("when the environment variables are also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TEST", "false")
+				t.Setenv("BP_GO_VET_FLAGS", "")
+			})
+
+			it("gives the environment variables precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.VetPatterns).To(Equal([]string{"./cmd/..."}))
+				Expect(configuration.VetFlags).To(BeEmpty())
+				Expect(configuration.TestPatterns).To(BeEmpty())
+			})
+		})
+	})
+
+	context("when BP_GO_TEST_BINARIES is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_TEST_BINARIES", "./internal/store:./e2e/...")
+		})
+
+		it("uses the values in the env var", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.TestBinaryPatterns).To(Equal([]string{"./internal/store", "./e2e/..."}))
+		})
+	})
+
+	context("when the go-build.toml selects test binaries", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`
+test-binaries = ["./e2e/..."]
+`), 0600)).To(Succeed())
+		})
+
+		it("uses the values in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.TestBinaryPatterns).To(Equal([]string{"./e2e/..."}))
+		})
+
+		context("when BP_GO_TEST_BINARIES is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TEST_BINARIES", "false")
+			})
+
+			it("gives the environment variable precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.TestBinaryPatterns).To(BeEmpty())
+			})
+		})
+	})
+
+	context("when BP_GO_OFFLINE is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_OFFLINE", "true")
+		})
+
+		it("enables offline builds", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.Offline).To(BeTrue())
+		})
+	})
+
+	context("when the go-build.toml enables offline builds", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte("offline = true\n"), 0600)).To(Succeed())
+		})
+
+		it("uses the value in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.Offline).To(BeTrue())
+		})
+
+		context("when BP_GO_OFFLINE is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_OFFLINE", "false")
+			})
+
+			it("gives the environment variable precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.Offline).To(BeFalse())
+			})
+		})
+	})
+
+	context("when BP_GO_REPRODUCIBLE is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_REPRODUCIBLE", "true")
+		})
+
+		it("enables reproducible builds", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.Reproducible).To(BeTrue())
+		})
+	})
+
+	context("when the go-build.toml enables reproducible builds", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte("reproducible = true\n"), 0600)).To(Succeed())
+		})
+
+		it("uses the value in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.Reproducible).To(BeTrue())
+		})
+
+		context("when BP_GO_REPRODUCIBLE is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_REPRODUCIBLE", "false")
+			})
+
+			it("gives the environment variable precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.Reproducible).To(BeFalse())
+			})
+		})
+	})
+
+	context("when BP_GO_VERIFY_REPRODUCIBLE is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_VERIFY_REPRODUCIBLE", "true")
+		})
+
+		it("enables the reproducibility verification", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.VerifyReproducible).To(BeTrue())
+		})
+	})
+
+	context("when the go-build.toml enables the reproducibility verification", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte("verify-reproducible = true\n"), 0600)).To(Succeed())
+		})
+
+		it("uses the value in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.VerifyReproducible).To(BeTrue())
+		})
+
+		context("when BP_GO_VERIFY_REPRODUCIBLE is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_VERIFY_REPRODUCIBLE", "false")
+			})
+
+			it("gives the environment variable precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.VerifyReproducible).To(BeFalse())
+			})
+		})
+	})
+
+	context("when BP_GO_PROVENANCE_LABEL is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_PROVENANCE_LABEL", "true")
+		})
+
+		it("enables the provenance label", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.ProvenanceLabel).To(BeTrue())
+		})
+	})
+
+	context("when the go-build.toml enables the provenance label", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte("provenance-label = true\n"), 0600)).To(Succeed())
+		})
+
+		it("uses the value in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.ProvenanceLabel).To(BeTrue())
+		})
+
+		context("when BP_GO_PROVENANCE_LABEL is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_PROVENANCE_LABEL", "false")
+			})
+
+			it("gives the environment variable precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.ProvenanceLabel).To(BeFalse())
+			})
+		})
+	})
+
+	context("when the BP_GO_VULNCHECK variables are set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_VULNCHECK", "true")
+			t.Setenv("BP_GO_VULNCHECK_DB", "/some/vulndb")
+			t.Setenv("BP_GO_VULNCHECK_ALLOW", "GO-2024-0001:CVE-2024-0002")
+			t.Setenv("BP_GO_VULNCHECK_ALLOW_SEVERITIES", "LOW:MODERATE")
+		})
+
+		it("enables vulnerability scanning with the given policy", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.VulnCheck).To(BeTrue())
+			Expect(configuration.VulnCheckDatabase).To(Equal("/some/vulndb"))
+			Expect(configuration.VulnCheckAllow).To(Equal([]string{"GO-2024-0001", "CVE-2024-0002"}))
+			Expect(configuration.VulnCheckAllowSeverities).To(Equal([]string{"LOW", "MODERATE"}))
+		})
+
+		context("when the database path is relative", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_VULNCHECK_DB", "vulndb")
+			})
+
+			it("resolves it from the working directory", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.VulnCheckDatabase).To(Equal(filepath.Join(workingDir, "vulndb")))
+			})
+		})
+	})
+
+	context("when the go-build.toml configures vulnerability scanning", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`vulncheck = true
+vulncheck-db = "vulndb"
+vulncheck-allow = ["GO-2024-0001"]
+vulncheck-allow-severities = ["LOW"]
+`), 0600)).To(Succeed())
+		})
+
+		it("uses the values in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.VulnCheck).To(BeTrue())
+			Expect(configuration.VulnCheckDatabase).To(Equal(filepath.Join(workingDir, "vulndb")))
+			Expect(configuration.VulnCheckAllow).To(Equal([]string{"GO-2024-0001"}))
+			Expect(configuration.VulnCheckAllowSeverities).To(Equal([]string{"LOW"}))
+		})
+
+		context("when the BP_GO_VULNCHECK variables are also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_VULNCHECK", "false")
+				t.Setenv("BP_GO_VULNCHECK_ALLOW", "")
+			})
+
+			it("gives the environment variables precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.VulnCheck).To(BeFalse())
+				Expect(configuration.VulnCheckAllow).To(BeEmpty())
+			})
+		})
+	})
+
+	context("when the BP_GO_LICENSES variables are set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_LICENSES", "true")
+			t.Setenv("BP_GO_LICENSES_ALLOW", "MIT:Apache-2.0")
+			t.Setenv("BP_GO_LICENSES_DENY", "GPL-3.0:AGPL-3.0")
+		})
+
+		it("enables license collection with the given policy", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.Licenses).To(BeTrue())
+			Expect(configuration.LicensesAllow).To(Equal([]string{"MIT", "Apache-2.0"}))
+			Expect(configuration.LicensesDeny).To(Equal([]string{"GPL-3.0", "AGPL-3.0"}))
+		})
+	})
+
+	context("when the go-build.toml configures license collection", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`licenses = true
+licenses-allow = ["MIT"]
+licenses-deny = ["GPL-3.0"]
+`), 0600)).To(Succeed())
+		})
+
+		it("uses the values in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.Licenses).To(BeTrue())
+			Expect(configuration.LicensesAllow).To(Equal([]string{"MIT"}))
+			Expect(configuration.LicensesDeny).To(Equal([]string{"GPL-3.0"}))
+		})
+
+		context("when the BP_GO_LICENSES variables are also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_LICENSES", "false")
+				t.Setenv("BP_GO_LICENSES_DENY", "AGPL-3.0")
+			})
+
+			it("gives the environment variables precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.Licenses).To(BeFalse())
+				Expect(configuration.LicensesDeny).To(Equal([]string{"AGPL-3.0"}))
+			})
+		})
+	})
+
+	context("when the BP_GO_MODULES variables are set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_MODULES_DENY", "example.com/denied:*.internal/*")
+			t.Setenv("BP_GO_MODULES_DENY_PSEUDO_VERSIONS", "github.com/some-org")
+			t.Setenv("BP_GO_MODULES_DENY_EXTERNAL_REPLACE", "true")
+			t.Setenv("BP_GO_MODULES_REQUIRE_TOOLCHAIN", "true")
+		})
+
+		it("configures the module policy", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.ModulesDeny).To(Equal([]string{"example.com/denied", "*.internal/*"}))
+			Expect(configuration.ModulesDenyPseudoVersions).To(Equal([]string{"github.com/some-org"}))
+			Expect(configuration.ModulesDenyExternalReplace).To(BeTrue())
+			Expect(configuration.ModulesRequireToolchain).To(BeTrue())
+		})
+	})
+
+	context("when the go-build.toml configures the module policy", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`[modules]
+deny = ["example.com/denied"]
+deny-pseudo-versions = ["github.com/some-org"]
+deny-external-replace = true
+require-toolchain = true
+`), 0600)).To(Succeed())
+		})
+
+		it("uses the values in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.ModulesDeny).To(Equal([]string{"example.com/denied"}))
+			Expect(configuration.ModulesDenyPseudoVersions).To(Equal([]string{"github.com/some-org"}))
+			Expect(configuration.ModulesDenyExternalReplace).To(BeTrue())
+			Expect(configuration.ModulesRequireToolchain).To(BeTrue())
+		})
+
+		context("when the BP_GO_MODULES variables are also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_MODULES_DENY", "example.com/other")
+				t.Setenv("BP_GO_MODULES_REQUIRE_TOOLCHAIN", "false")
+			})
+
+			it("gives the environment variables precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.ModulesDeny).To(Equal([]string{"example.com/other"}))
+				Expect(configuration.ModulesRequireToolchain).To(BeFalse())
+			})
+		})
+	})
+
+	context("when BP_GO_DEBUG is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_DEBUG", "true")
+		})
+
+		it("enables debug mode on the default delve port", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.Debug).To(BeTrue())
+			Expect(configuration.DebugPort).To(Equal(2345))
+		})
+
+		context("when BP_GO_DEBUG_PORT is set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_DEBUG_PORT", "40000")
+			})
+
+			it("uses the given port", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.DebugPort).To(Equal(40000))
+			})
+		})
+	})
+
+	context("when the go-build.toml enables debug mode", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`debug = true
+debug-port = 40000
+`), 0600)).To(Succeed())
+		})
+
+		it("uses the values in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.Debug).To(BeTrue())
+			Expect(configuration.DebugPort).To(Equal(40000))
+		})
+
+		context("when the BP_GO_DEBUG variables are also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_DEBUG_PORT", "40001")
+			})
+
+			it("gives the environment variables precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.DebugPort).To(Equal(40001))
+			})
+		})
+
+		context("when BP_GO_DEBUG disables debug mode", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_DEBUG", "false")
+			})
+
+			it("does not configure a port", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.Debug).To(BeFalse())
+				Expect(configuration.DebugPort).To(BeZero())
+			})
+		})
+	})
+
+	context("when BP_GO_SPLIT_DEBUG_SYMBOLS is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_SPLIT_DEBUG_SYMBOLS", "true")
+		})
+
+		it("splits the debug symbols from the binaries", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.SplitDebugSymbols).To(BeTrue())
+		})
+	})
+
+	context("when the go-build.toml splits the debug symbols", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`split-debug-symbols = true
+`), 0600)).To(Succeed())
+		})
+
+		it("uses the value in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.SplitDebugSymbols).To(BeTrue())
+		})
+
+		context("when BP_GO_SPLIT_DEBUG_SYMBOLS is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_SPLIT_DEBUG_SYMBOLS", "false")
+			})
+
+			it("gives the environment variable precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.SplitDebugSymbols).To(BeFalse())
+			})
+		})
+	})
+
+	context("when BP_GO_PGO is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_GO_PGO", "profiles/merged.pgo")
+		})
+
+		it("resolves the profile from the root of the application", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.PGO).To(Equal(filepath.Join(workingDir, "profiles", "merged.pgo")))
+		})
+
+		context("when the profile is an absolute path", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_PGO", "/profiles/merged.pgo")
+			})
+
+			it("uses the path as it is", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.PGO).To(Equal("/profiles/merged.pgo"))
+			})
+		})
+
+		context("when PGO is turned off", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_PGO", "off")
+			})
+
+			it("keeps the value", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.PGO).To(Equal("off"))
+			})
+		})
+	})
+
+	context("when the go-build.toml configures a PGO profile", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`pgo = "profiles/merged.pgo"
+`), 0600)).To(Succeed())
+		})
+
+		it("uses the value in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration.PGO).To(Equal(filepath.Join(workingDir, "profiles", "merged.pgo")))
+		})
+
+		context("when BP_GO_PGO is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_PGO", "off")
+			})
+
+			it("gives the environment variable precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.PGO).To(Equal("off"))
+			})
+		})
+	})
+
+	context("when BP_KEEP_FILES is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_KEEP_FILES", "assets/*:public/*")
+		})
+
+		it("uses the values in the env var", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration).To(Equal(gobuild.BuildConfiguration{
+				Targets:   []string{"."},
+				KeepFiles: []string{"assets/*", "public/*"},
+			}))
+		})
+	})
+
+	context("when the working directory contains a go-build.toml", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`
+targets = ["./cmd/server", "./cmd/worker"]
+flags = ["-buildmode=default", "-tags=paketo"]
+ldflags = "-X main.variable=some-value"
+import-path = "example.com/some-app"
+work-use = ["./some/module1", "./some/module2"]
+keep-files = ["assets/*"]
+
+[process]
+  default = "worker"
+`), 0600)).To(Succeed())
+
+			for _, module := range []string{"module1", "module2", "module3"} {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "some", module), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "some", module, "go.mod"), nil, 0600)).To(Succeed())
+			}
+
+			targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./cmd/server", "./cmd/worker"}
+		})
+
+		it("uses the values in the file", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration).To(Equal(gobuild.BuildConfiguration{
+				Targets: []string{"./cmd/server", "./cmd/worker"},
+				Flags: []string{
+					"-buildmode=default",
+					"-tags=paketo",
+					"-ldflags=-X main.variable=some-value",
+				},
+				ImportPath:          "example.com/some-app",
+				WorkspaceUseModules: []string{"./some/module1", "./some/module2"},
+				KeepFiles:           []string{"assets/*"},
+				DefaultProcess:      "worker",
+			}))
+
+			Expect(targetManager.CleanAndValidateCall.Receives.Targets).To(Equal([]string{"./cmd/server", "./cmd/worker"}))
+			Expect(targetManager.GenerateDefaultsCall.CallCount).To(Equal(0))
+		})
+
+		context("when the environment variables are also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS", "./cmd/server")
+				t.Setenv("BP_GO_BUILD_LDFLAGS", "-X main.variable=env-value")
+				t.Setenv("BP_GO_BUILD_IMPORT_PATH", "example.com/env-app")
+				t.Setenv("BP_GO_WORK_USE", "./some/module3")
+				t.Setenv("BP_KEEP_FILES", "public/*")
+
+				targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./cmd/server"}
+			})
+
+			it("gives the environment variables precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration).To(Equal(gobuild.BuildConfiguration{
+					Targets: []string{"./cmd/server"},
+					Flags: []string{
+						"-buildmode=default",
+						"-tags=paketo",
+						"-ldflags=-X main.variable=env-value",
+					},
+					ImportPath:          "example.com/env-app",
+					WorkspaceUseModules: []string{"./some/module3"},
+					KeepFiles:           []string{"public/*"},
+					DefaultProcess:      "worker",
+				}))
+
+				Expect(targetManager.CleanAndValidateCall.Receives.Targets).To(Equal([]string{"./cmd/server"}))
+			})
+		})
+
+		context("when BP_GO_BUILD_FLAGS is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_BUILD_FLAGS", "-race")
+			})
+
+			it("replaces the flags from the file", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.Flags).To(Equal([]string{"-race"}))
+			})
+		})
+
+		context("when BP_GO_WORKDIR contains its own go-build.toml", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "subdir"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "subdir", "go-build.toml"), []byte(`import-path = "example.com/subdir-app"`), 0600)).To(Succeed())
+				t.Setenv("BP_GO_WORKDIR", "subdir")
+			})
+
+			it("uses the file in the work directory", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration).To(Equal(gobuild.BuildConfiguration{
+					Targets:    []string{"."},
+					ImportPath: "example.com/subdir-app",
+					WorkDir:    "subdir",
+				}))
+			})
+		})
+	})
+
+	context("when the go-build.toml contains per-target settings", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`
+flags = ["-tags=paketo"]
+targets = [
+  "./cmd/worker",
+  { path = "cmd/server", tags = ["netgo", "osusergo"], ldflags = "-X main.mode=server", cgo = false },
+  { path = "./cmd/admin", buildmode = "default", flags = ["-race"], cgo = true },
+  { path = "./cmd/other", name = "other-cmd" },
+]
+`), 0600)).To(Succeed())
+
+			targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./cmd/worker", "./cmd/server", "./cmd/admin", "./cmd/other"}
+		})
+
+		it("returns the settings for each target", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration).To(Equal(gobuild.BuildConfiguration{
+				Targets: []string{"./cmd/worker", "./cmd/server", "./cmd/admin", "./cmd/other"},
+				TargetConfiguration: map[string]gobuild.TargetConfiguration{
+					"./cmd/server": {
+						Flags: []string{"-tags=netgo,osusergo", "-ldflags=-X main.mode=server"},
+						Env:   []string{"CGO_ENABLED=0"},
+					},
+					"./cmd/admin": {
+						Flags: []string{"-race", "-buildmode=default"},
+						Env:   []string{"CGO_ENABLED=1"},
+					},
+					"./cmd/other": {
+						Name: "other-cmd",
+					},
+				},
+				Flags: []string{"-tags=paketo"},
+			}))
+
+			Expect(targetManager.CleanAndValidateCall.Receives.Targets).To(Equal([]string{"./cmd/worker", "cmd/server", "./cmd/admin", "./cmd/other"}))
+		})
+
+		context("when BP_GO_TARGETS renames a target", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS", "api=./cmd/server:./cmd/other")
+				targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./cmd/server", "./cmd/other"}
+			})
+
+			it("combines the name with the settings from the file", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.TargetConfiguration).To(Equal(map[string]gobuild.TargetConfiguration{
+					"./cmd/server": {
+						Name:  "api",
+						Flags: []string{"-tags=netgo,osusergo", "-ldflags=-X main.mode=server"},
+						Env:   []string{"CGO_ENABLED=0"},
+					},
+					"./cmd/other": {
+						Name: "other-cmd",
+					},
+				}))
+			})
+		})
+
+		context("when BP_GO_TARGETS overrides the targets", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS", "./cmd/server")
+				targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./cmd/server"}
+			})
+
+			it("keeps the settings of the remaining targets", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.Targets).To(Equal([]string{"./cmd/server"}))
+				Expect(configuration.TargetConfiguration).To(Equal(map[string]gobuild.TargetConfiguration{
+					"./cmd/server": {
+						Flags: []string{"-tags=netgo,osusergo", "-ldflags=-X main.mode=server"},
+						Env:   []string{"CGO_ENABLED=0"},
+					},
+				}))
+			})
+		})
+	})
+
+	context("when the go-build.toml contains package patterns", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`
+targets = [
+  { path = "./cmd/...", cgo = false },
+  { path = "./cmd/admin", cgo = true },
+]
+targets-exclude = ["./cmd/examples/..."]
+`), 0600)).To(Succeed())
+
+			targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./cmd/admin", "./cmd/examples/hello", "./cmd/server"}
+		})
+
+		it("applies the settings and exclusions to every matching target", func() {
+			configuration, err := parser.Parse("1.2.3", workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configuration).To(Equal(gobuild.BuildConfiguration{
+				Targets: []string{"./cmd/admin", "./cmd/server"},
+				TargetConfiguration: map[string]gobuild.TargetConfiguration{
+					"./cmd/admin":  {Env: []string{"CGO_ENABLED=1"}},
+					"./cmd/server": {Env: []string{"CGO_ENABLED=0"}},
+				},
+			}))
+
+			Expect(targetManager.CleanAndValidateCall.Receives.Targets).To(Equal([]string{"./cmd/...", "./cmd/admin"}))
+		})
+
+		context("when BP_GO_TARGETS_EXCLUDE is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS_EXCLUDE", "./cmd/server")
+			})
+
+			it("gives the environment variable precedence", func() {
+				configuration, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configuration.Targets).To(Equal([]string{"./cmd/admin", "./cmd/examples/hello"}))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when BP_GO_OFFLINE is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_OFFLINE", "sometimes")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_OFFLINE:")))
+			})
+		})
+
+		context("when BP_GO_REPRODUCIBLE is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_REPRODUCIBLE", "mostly")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_REPRODUCIBLE:")))
+			})
+		})
+
+		context("when BP_GO_VERIFY_REPRODUCIBLE is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_VERIFY_REPRODUCIBLE", "twice")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_VERIFY_REPRODUCIBLE:")))
+			})
+		})
+
+		context("when BP_GO_PROVENANCE_LABEL is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_PROVENANCE_LABEL", "label")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_PROVENANCE_LABEL:")))
+			})
+		})
+
+		context("when BP_GO_VULNCHECK is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_VULNCHECK", "scan")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_VULNCHECK:")))
+			})
+		})
+
+		context("when BP_GO_LICENSES is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_LICENSES", "collect")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_LICENSES:")))
+			})
+		})
+
+		context("when BP_GO_MODULES_DENY contains an invalid pattern", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_MODULES_DENY", "example.com/[denied")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(`invalid module pattern "example.com/[denied" in BP_GO_MODULES_DENY: syntax error in pattern`))
+			})
+		})
+
+		context("when BP_GO_MODULES_DENY_PSEUDO_VERSIONS contains an invalid pattern", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_MODULES_DENY_PSEUDO_VERSIONS", "example.com/[denied")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("invalid module pattern \"example.com/[denied\" in BP_GO_MODULES_DENY_PSEUDO_VERSIONS")))
+			})
+		})
+
+		context("when BP_GO_MODULES_DENY_EXTERNAL_REPLACE is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_MODULES_DENY_EXTERNAL_REPLACE", "deny")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_MODULES_DENY_EXTERNAL_REPLACE:")))
+			})
+		})
+
+		context("when BP_GO_MODULES_REQUIRE_TOOLCHAIN is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_MODULES_REQUIRE_TOOLCHAIN", "require")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_MODULES_REQUIRE_TOOLCHAIN:")))
+			})
+		})
+
+		context("when BP_GO_DEBUG is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_DEBUG", "debug")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_DEBUG:")))
+			})
+		})
+
+		context("when BP_GO_DEBUG_PORT is not a number", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_DEBUG", "true")
+				t.Setenv("BP_GO_DEBUG_PORT", "delve")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_DEBUG_PORT:")))
+			})
+		})
+
+		context("when BP_GO_DEBUG_PORT is out of range", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_DEBUG", "true")
+				t.Setenv("BP_GO_DEBUG_PORT", "70000")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError("debug port must be between 1 and 65535, got 70000"))
+			})
+		})
+
+		context("when BP_GO_SPLIT_DEBUG_SYMBOLS is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_SPLIT_DEBUG_SYMBOLS", "split")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_SPLIT_DEBUG_SYMBOLS:")))
+			})
+		})
+
+		context("when debug mode is combined with splitting the debug symbols", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_DEBUG", "true")
+				t.Setenv("BP_GO_SPLIT_DEBUG_SYMBOLS", "true")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError("BP_GO_DEBUG cannot be combined with BP_GO_SPLIT_DEBUG_SYMBOLS, debug mode keeps the debug symbols in the binaries"))
+			})
+		})
+
+		context("when BP_GO_TARGETS_DEPTH is not a number", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS_DEPTH", "deep")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_TARGETS_DEPTH:")))
+			})
+		})
+
+		context("when the targets depth is negative", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS_DEPTH", "-1")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError("targets depth must not be negative, got -1"))
+			})
+		})
+
+		context("when every target is excluded", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS_EXCLUDE", "./...")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(`failed to determine build targets: all targets were excluded by "./..."`))
+			})
+		})
+
+		context("when a package pattern is given a binary name", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS", "api=./cmd/...")
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(`binary name "api" cannot be given to the package pattern "./cmd/..."`))
+			})
+		})
+
+		context("when a go-build.toml package pattern is given a binary name", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`targets = [{ path = "./cmd/...", name = "api" }]`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(`binary name "api" cannot be given to the package pattern "./cmd/..."`))
+			})
+		})
+
+		context("when a target is given an invalid binary name", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS", "bin/api=./some/target")
+				targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./some/target"}
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(`invalid binary name "bin/api" for target "./some/target"`))
+			})
+		})
+
+		context("when two targets are given the same binary name", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TARGETS", "api=./some/target1:api=./some/target2")
+				targetManager.CleanAndValidateCall.Returns.StringSlice = []string{"./some/target1", "./some/target2"}
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(`targets "./some/target1" and "./some/target2" are both named "api"`))
+			})
+		})
+
+		context("when BP_GO_TEST_FLAGS fails to parse", func() {
+			it.Before(func() {
+				t.Setenv("BP_GO_TEST_FLAGS", `-run "unterminated`)
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_GO_TEST_FLAGS:")))
+			})
+		})
+
+		context("when the go-build.toml generate key has the wrong type", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`generate = "yes"`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring(`(last key "generate"): must be a boolean or a list of package patterns`)))
+			})
+		})
+
+		context("when a go-build.toml target contains an unknown key", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`targets = [{ path = "./cmd/server", gcflags = "-N" }]`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring(`unknown target key "gcflags"`)))
+			})
+		})
+
+		context("when a go-build.toml target is missing a path", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`targets = [{ tags = ["netgo"] }]`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("target is missing a path")))
+			})
+		})
+
+		context("when a go-build.toml target has a value of the wrong type", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`targets = [{ path = "./cmd/server", cgo = "yes" }]`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring(`target key "cgo" must be a boolean`)))
+			})
+		})
+
+		context("when the go-build.toml is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte("%%%"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse go-build.toml:")))
+			})
+		})
+
+		context("when the go-build.toml contains unknown keys", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go-build.toml"), []byte(`
+target = "./cmd/server"
+
+[process]
+  type = "server"
+`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.Parse("1.2.3", workingDir)
+				Expect(err).To(MatchError("failed to parse go-build.toml: unknown keys: process.type, target"))
+			})
+		})
+
 		context("when the working directory contains a buildpack.yml", func() {
 			it.Before(func() {
 				Expect(os.WriteFile(filepath.Join(workingDir, "buildpack.yml"), nil, os.ModePerm)).To(Succeed())
 			})
 			it("returns an error", func() {
 				_, err := parser.Parse("1.2.3", workingDir)
-				Expect(err).To(MatchError("working directory contains deprecated 'buildpack.yml'; use environment variables for configuration"))
+				Expect(err).To(MatchError("working directory contains deprecated 'buildpack.yml'; use environment variables or go-build.toml for configuration"))
 			})
 			context("and it's not readable", func() {
 				it.Before(func() {
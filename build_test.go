@@ -3,10 +3,13 @@ package gobuild_test
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
 	"testing"
 
 	gobuild "github.com/paketo-buildpacks/go-build"
@@ -15,6 +18,7 @@ import (
 	"github.com/paketo-buildpacks/packit/v2/chronos"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 	"github.com/sclevine/spec"
 
 	. "github.com/onsi/gomega"
@@ -34,6 +38,12 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		sourceRemover *fakes.SourceRemover
 		parser        *fakes.ConfigurationParser
 		sbomGenerator *fakes.SBOMGenerator
+		calculator    *fakes.ChecksumCalculator
+		toolchain     *fakes.Toolchain
+		scanner       *fakes.VulnerabilityScanner
+		collector     *fakes.LicenseCollector
+		profiles      *fakes.ProfileResolver
+		bindings      *fakes.BindingResolver
 
 		build packit.BuildFunc
 	)
@@ -70,6 +80,17 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		sbomGenerator = &fakes.SBOMGenerator{}
 		sbomGenerator.GenerateCall.Returns.SBOM = sbom.SBOM{}
 
+		calculator = &fakes.ChecksumCalculator{}
+		calculator.SumCall.Returns.String = "some-workspace-sha"
+
+		toolchain = &fakes.Toolchain{}
+		toolchain.VersionCall.Returns.String = "go1.22.4"
+
+		scanner = &fakes.VulnerabilityScanner{}
+		collector = &fakes.LicenseCollector{}
+		profiles = &fakes.ProfileResolver{}
+		bindings = &fakes.BindingResolver{}
+
 		build = gobuild.Build(
 			parser,
 			buildProcess,
@@ -78,6 +99,12 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			scribe.NewEmitter(logs),
 			sourceRemover,
 			sbomGenerator,
+			calculator,
+			toolchain,
+			scanner,
+			collector,
+			profiles,
+			bindings,
 		)
 	})
 
@@ -91,6 +118,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		result, err := build(packit.BuildContext{
 			WorkingDir: workingDir,
 			CNBPath:    cnbDir,
+			Platform:   packit.Platform{Path: "some-platform-path"},
 			Stack:      "some-stack",
 			BuildpackInfo: packit.BuildpackInfo{
 				Name:        "Some Buildpack",
@@ -101,7 +129,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(result.Layers).To(HaveLen(2))
+		Expect(result.Layers).To(HaveLen(3))
 
 		targets := result.Layers[0]
 		Expect(targets.Name).To(Equal("targets"))
@@ -109,6 +137,8 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		Expect(targets.Build).To(BeFalse())
 		Expect(targets.Cache).To(BeFalse())
 		Expect(targets.Launch).To(BeTrue())
+		Expect(targets.Metadata).To(HaveKeyWithValue("workspace_sha", MatchRegexp(`^[0-9a-f]{64}$`)))
+		Expect(targets.Metadata).To(HaveKeyWithValue("binaries", []string{"path/some-start-command", "path/another-start-command"}))
 
 		Expect(targets.SBOM.Formats()).To(HaveLen(2))
 		cdx := targets.SBOM.Formats()[0]
@@ -184,6 +214,16 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		Expect(gocache.Cache).To(BeTrue())
 		Expect(gocache.Launch).To(BeFalse())
 
+		gomodcache := result.Layers[2]
+		Expect(gomodcache.Name).To(Equal("gomodcache"))
+		Expect(gomodcache.Path).To(Equal(filepath.Join(layersDir, "gomodcache")))
+		Expect(gomodcache.Build).To(BeFalse())
+		Expect(gomodcache.Cache).To(BeTrue())
+		Expect(gomodcache.Launch).To(BeFalse())
+		Expect(gomodcache.Metadata).To(Equal(map[string]interface{}{
+			"module_sums_sha": "",
+		}))
+
 		Expect(result.Launch.Processes).To(Equal([]packit.Process{
 			{
 				Type:    "some-start-command",
@@ -201,22 +241,35 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		Expect(parser.ParseCall.Receives.BuildpackVersion).To(Equal("some-version"))
 		Expect(parser.ParseCall.Receives.WorkingDir).To(Equal(workingDir))
 
+		// The workspace and each of the binaries are checksummed
+		Expect(calculator.SumCall.CallCount).To(Equal(3))
+		Expect(toolchain.VersionCall.CallCount).To(Equal(1))
+
 		Expect(pathManager.SetupCall.Receives.Workspace).To(Equal(workingDir))
 		Expect(pathManager.SetupCall.Receives.ImportPath).To(Equal("some-import-path"))
 
 		Expect(buildProcess.ExecuteCall.Receives.Config).To(Equal(gobuild.GoBuildConfiguration{
-			Workspace: "some-app-path",
-			Output:    filepath.Join(layersDir, "targets", "bin"),
-			GoPath:    "some-go-path",
-			GoCache:   filepath.Join(layersDir, "gocache"),
-			Flags:     []string{"some-flag", "other-flag"},
-			Targets:   []string{"some-target", "other-target"},
+			Workspace:        "some-app-path",
+			PlatformPath:     "some-platform-path",
+			Output:           filepath.Join(layersDir, "targets", "bin"),
+			GoPath:           "some-go-path",
+			GoCache:          filepath.Join(layersDir, "gocache"),
+			GoModCache:       filepath.Join(layersDir, "gomodcache"),
+			Flags:            []string{"some-flag", "other-flag"},
+			Targets:          []string{"some-target", "other-target"},
+			TestBinaryOutput: filepath.Join(layersDir, "targets", "tests"),
 		}))
+		Expect(buildProcess.CompileTestsCall.CallCount).To(Equal(0))
 
 		Expect(pathManager.TeardownCall.Receives.GoPath).To(Equal("some-go-path"))
+		Expect(scanner.ScanCall.CallCount).To(Equal(0))
+		Expect(scanner.DatabaseVersionCall.CallCount).To(Equal(0))
+		Expect(collector.CollectCall.CallCount).To(Equal(0))
 
 		Expect(sourceRemover.ClearCall.Receives.Path).To(Equal(workingDir))
-		Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(filepath.Join(targets.Path, "bin")))
+		Expect(sourceRemover.ClearCall.Receives.KeepFiles).To(BeNil())
+		Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(targets.Path))
+		Expect(filepath.Join(targets.Path, "provenance.intoto.json")).To(BeARegularFile())
 
 		Expect(logs.String()).To(ContainSubstring("Some Buildpack some-version"))
 		Expect(logs.String()).To(ContainSubstring("Assigning launch processes"))
@@ -238,6 +291,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 					Name:    "Some Buildpack",
 					Version: "some-version",
 				},
+				Layers: packit.Layers{Path: layersDir},
 			})
 			Expect(err).NotTo(HaveOccurred())
 
@@ -384,7 +438,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(result.Layers).To(HaveLen(2))
+			Expect(result.Layers).To(HaveLen(3))
 			Expect(result.Launch.Processes).To(HaveLen(2))
 
 			Expect(pathManager.SetupCall.Receives.Workspace).To(Equal(filepath.Join(workingDir, "main")))
@@ -392,162 +446,1943 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
-	context("failure cases", func() {
-		context("when the targets layer cannot be retrieved", func() {
+	context("when go generate should be run", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.GeneratePatterns = []string{"./api/..."}
+		})
+
+		it("passes the patterns to the build process", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buildProcess.ExecuteCall.Receives.Config.GeneratePatterns).To(Equal([]string{"./api/..."}))
+		})
+	})
+
+	context("when the ldflags contain templates", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(layersDir, "project-metadata.toml"), []byte(`
+[source]
+  type = "git"
+  [source.version]
+    commit = "some-commit"
+  [source.metadata]
+    repository = "https://github.com/some-org/some-app"
+    refs = ["main", "v1.2.3"]
+`), 0600)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "go.mod"), []byte("module example.com/some-app\n"), 0600)).To(Succeed())
+			t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+			parser.ParseCall.Returns.BuildConfiguration.Flags = []string{
+				"-ldflags=-X main.version={{.Version}} -X main.commit={{.Commit}}",
+				"-tags={{.Version}}",
+			}
+			parser.ParseCall.Returns.BuildConfiguration.TargetConfiguration = map[string]gobuild.TargetConfiguration{
+				"some-target": {Flags: []string{"-ldflags", "-X {{.ModulePath}}/internal.date={{.BuildDate}}"}},
+			}
+
+			buildContext = packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: filepath.Join(layersDir, "some-buildpack")},
+			}
+		})
+
+		it("fills them in from the project metadata, SOURCE_DATE_EPOCH and go.mod", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			config := buildProcess.ExecuteCall.Receives.Config
+			Expect(config.Flags).To(Equal([]string{
+				"-ldflags=-X main.version=v1.2.3 -X main.commit=some-commit",
+				"-tags={{.Version}}",
+			}))
+			Expect(config.TargetConfiguration).To(Equal(map[string]gobuild.TargetConfiguration{
+				"some-target": {Flags: []string{"-ldflags", "-X example.com/some-app/internal.date=2023-11-14T22:13:20Z"}},
+			}))
+		})
+
+		context("when the project metadata is not provided", func() {
 			it.Before(func() {
-				Expect(os.WriteFile(filepath.Join(layersDir, "targets.toml"), nil, 0000)).To(Succeed())
+				Expect(os.Remove(filepath.Join(layersDir, "project-metadata.toml"))).To(Succeed())
 			})
 
 			it("returns an error", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Stack:      "some-stack",
-					BuildpackInfo: packit.BuildpackInfo{
-						Name:    "Some Buildpack",
-						Version: "some-version",
-					},
-					Layers: packit.Layers{Path: layersDir},
-				})
-				Expect(err).To(MatchError(ContainSubstring("failed to parse layer content metadata")))
-				Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`failed to parse build configuration: failed to render ldflags template "-X main.version={{.Version}} -X main.commit={{.Commit}}": {{.Version}} requires the platform to provide project-metadata.toml`))
 			})
 		})
 
-		context("when the gocache layer cannot be retrieved", func() {
+		context("when the project metadata does not contain a version tag", func() {
 			it.Before(func() {
-				Expect(os.WriteFile(filepath.Join(layersDir, "gocache.toml"), nil, 0000)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, "project-metadata.toml"), []byte(`
+[source]
+  [source.metadata]
+    refs = ["main"]
+`), 0600)).To(Succeed())
 			})
 
 			it("returns an error", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Stack:      "some-stack",
-					BuildpackInfo: packit.BuildpackInfo{
-						Name:    "Some Buildpack",
-						Version: "some-version",
-					},
-					Layers: packit.Layers{Path: layersDir},
-				})
-				Expect(err).To(MatchError(ContainSubstring("failed to parse layer content metadata")))
-				Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("{{.Version}} requires a version tag in source.metadata.refs of project-metadata.toml")))
 			})
 		})
 
-		context("when the go path cannot be setup", func() {
+		context("when the project metadata does not contain a commit", func() {
 			it.Before(func() {
-				pathManager.SetupCall.Returns.Err = errors.New("failed to setup go path")
+				Expect(os.WriteFile(filepath.Join(layersDir, "project-metadata.toml"), []byte(`
+[source]
+  [source.metadata]
+    refs = ["v1.2.3"]
+`), 0600)).To(Succeed())
 			})
 
 			it("returns an error", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Stack:      "some-stack",
-					BuildpackInfo: packit.BuildpackInfo{
-						Name:    "Some Buildpack",
-						Version: "some-version",
-					},
-					Layers: packit.Layers{Path: layersDir},
-				})
-				Expect(err).To(MatchError("failed to setup go path"))
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("{{.Commit}} requires source.version.commit in project-metadata.toml")))
 			})
 		})
 
-		context("when the build process fails", func() {
+		context("when SOURCE_DATE_EPOCH is not set", func() {
 			it.Before(func() {
-				buildProcess.ExecuteCall.Returns.Err = errors.New("failed to execute build process")
+				Expect(os.Unsetenv("SOURCE_DATE_EPOCH")).To(Succeed())
 			})
 
 			it("returns an error", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Stack:      "some-stack",
-					BuildpackInfo: packit.BuildpackInfo{
-						Name:    "Some Buildpack",
-						Version: "some-version",
-					},
-					Layers: packit.Layers{Path: layersDir},
-				})
-				Expect(err).To(MatchError("failed to execute build process"))
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`failed to parse build configuration: target "some-target": failed to render ldflags template "-X {{.ModulePath}}/internal.date={{.BuildDate}}": {{.BuildDate}} requires SOURCE_DATE_EPOCH to be set`))
 			})
 		})
 
-		context("when the go path cannot be torn down", func() {
+		context("when SOURCE_DATE_EPOCH is not a number", func() {
 			it.Before(func() {
-				pathManager.TeardownCall.Returns.Error = errors.New("failed to teardown go path")
+				t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
 			})
 
 			it("returns an error", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Stack:      "some-stack",
-					BuildpackInfo: packit.BuildpackInfo{
-						Name:    "Some Buildpack",
-						Version: "some-version",
-					},
-					Layers: packit.Layers{Path: layersDir},
-				})
-				Expect(err).To(MatchError("failed to teardown go path"))
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse SOURCE_DATE_EPOCH:")))
 			})
 		})
 
-		context("when the source cannot be cleared", func() {
+		context("when there is no go.mod file", func() {
 			it.Before(func() {
-				sourceRemover.ClearCall.Returns.Error = errors.New("failed to remove source")
+				Expect(os.Remove(filepath.Join(workingDir, "go.mod"))).To(Succeed())
 			})
 
 			it("returns an error", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Stack:      "some-stack",
-					BuildpackInfo: packit.BuildpackInfo{
-						Name:    "Some Buildpack",
-						Version: "some-version",
-					},
-					Layers: packit.Layers{Path: layersDir},
-				})
-				Expect(err).To(MatchError("failed to remove source"))
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("{{.ModulePath}} requires a go.mod file")))
 			})
 		})
 
-		context("when an SBOM cannot be generated", func() {
+		context("when a template cannot be parsed", func() {
 			it.Before(func() {
-				sbomGenerator.GenerateCall.Returns.Error = errors.New("sbom generation error")
+				parser.ParseCall.Returns.BuildConfiguration.Flags = []string{"-ldflags=-X main.version={{.Version"}
 			})
-			it("fails the build and returns the error", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Stack:      "io.paketo.stacks.tiny",
-					BuildpackInfo: packit.BuildpackInfo{
-						Name:    "Some Buildpack",
-						Version: "some-version",
-					},
-					Layers: packit.Layers{Path: layersDir},
-				})
-				Expect(err).To(MatchError("sbom generation error"))
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse ldflags template:")))
 			})
 		})
 
-		context("when a requested SBOM format is invalid", func() {
-			it("fails the build and returns the error", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Stack:      "io.paketo.stacks.tiny",
-					BuildpackInfo: packit.BuildpackInfo{
-						Name:        "Some Buildpack",
-						Version:     "some-version",
-						SBOMFormats: []string{"invalid-format"},
-					},
-					Layers: packit.Layers{Path: layersDir},
-				})
-				Expect(err).To(MatchError(`unsupported SBOM format: 'invalid-format'`))
+		context("when a template uses an unknown variable", func() {
+			it.Before(func() {
+				parser.ParseCall.Returns.BuildConfiguration.Flags = []string{"-ldflags=-X main.branch={{.Branch}}"}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring(`can't evaluate field Branch`)))
+			})
+		})
+	})
+
+	context("when the build is offline", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.Offline = true
+		})
+
+		it("passes the setting to the build process", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buildProcess.ExecuteCall.Receives.Config.Offline).To(BeTrue())
+		})
+	})
+
+	context("when the build is reproducible", func() {
+		it.Before(func() {
+			buildProcess.ExecuteCall.Returns.Details.Env = []string{"GOFLAGS=-modcacherw -mod=vendor", "LC_ALL=C", "SOURCE_DATE_EPOCH=1700000000", "TZ=UTC"}
+			parser.ParseCall.Returns.BuildConfiguration.Reproducible = true
+			parser.ParseCall.Returns.BuildConfiguration.Flags = []string{"-tags=paketo"}
+		})
+
+		it("records the inputs of the build in the targets layer metadata", func() {
+			result, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buildProcess.ExecuteCall.Receives.Config.Reproducible).To(BeTrue())
+			Expect(buildProcess.ExecuteCall.Receives.Config.VerifyReproducible).To(BeFalse())
+
+			targets := result.Layers[0]
+			Expect(targets.Metadata).To(HaveKey("reproducibility"))
+
+			record := targets.Metadata["reproducibility"].(map[string]interface{})
+			Expect(record).To(HaveKeyWithValue("go_version", "go1.22.4"))
+			Expect(record).To(HaveKeyWithValue("stack", "some-stack"))
+			Expect(record).To(HaveKeyWithValue("env", []string{"GOFLAGS=-modcacherw -mod=vendor", "LC_ALL=C", "SOURCE_DATE_EPOCH=1700000000", "TZ=UTC"}))
+			Expect(record).To(HaveKeyWithValue("targets", []map[string]interface{}{
+				{
+					"target": "some-target",
+					"flags":  []string{"-tags=paketo", "-buildmode", "pie", "-trimpath", "-buildvcs=false", "-ldflags=-buildid="},
+				},
+				{
+					"target": "other-target",
+					"flags":  []string{"-tags=paketo", "-buildmode", "pie", "-trimpath", "-buildvcs=false", "-ldflags=-buildid="},
+				},
+			}))
+		})
+	})
+
+	context("when the build should be verified to be reproducible", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.VerifyReproducible = true
+		})
+
+		it("passes the setting to the build process", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buildProcess.ExecuteCall.Receives.Config.VerifyReproducible).To(BeTrue())
+		})
+	})
+
+	context("when the build is not reproducible", func() {
+		it("does not record the inputs of the build", func() {
+			result, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers[0].Metadata).NotTo(HaveKey("reproducibility"))
+		})
+	})
+
+	context("when the provenance is written", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			calculator.SumCall.Stub = func(paths ...string) (string, error) {
+				if paths[0] == workingDir {
+					return "some-workspace-sha", nil
+				}
+				return fmt.Sprintf("%s-sha", filepath.Base(paths[0])), nil
+			}
+
+			buildProcess.ExecuteCall.Returns.Details.Commands = [][]string{
+				{"go", "build", "-o", "some-output", "-buildmode", "pie", "-trimpath", "./some-target", "./other-target"},
+			}
+			buildProcess.CompileTestsCall.Returns.Binaries = []string{"path/store.test"}
+			parser.ParseCall.Returns.BuildConfiguration.TestBinaryPatterns = []string{"./store"}
+
+			Expect(os.WriteFile(filepath.Join(layersDir, "project-metadata.toml"), []byte(`
+[source]
+type = "git"
+
+[source.version]
+commit = "some-commit"
+
+[source.metadata]
+repository = "https://github.com/some-org/some-repo"
+`), 0600)).To(Succeed())
+
+			buildContext = packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					ID:      "some-org/some-buildpack",
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: filepath.Join(layersDir, "some-buildpack")},
+			}
+		})
+
+		it("writes an in-toto provenance statement to the targets layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Labels).To(BeEmpty())
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "some-buildpack", "targets", "provenance.intoto.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+				"_type": "https://in-toto.io/Statement/v1",
+				"subject": [
+					{"name": "path/some-start-command", "digest": {"sha256": "some-start-command-sha"}},
+					{"name": "path/another-start-command", "digest": {"sha256": "another-start-command-sha"}},
+					{"name": "path/store.test", "digest": {"sha256": "store.test-sha"}}
+				],
+				"predicateType": "https://slsa.dev/provenance/v1",
+				"predicate": {
+					"buildDefinition": {
+						"buildType": "https://github.com/paketo-buildpacks/go-build#provenance",
+						"externalParameters": {
+							"configuration": {
+								"Targets": ["some-target", "other-target"],
+								"TargetConfiguration": null,
+								"Flags": ["some-flag", "other-flag"],
+								"ImportPath": "some-import-path",
+								"WorkspaceUseModules": null,
+								"Offline": false,
+								"Reproducible": false,
+								"VerifyReproducible": false,
+								"ProvenanceLabel": false,
+								"VulnCheck": false,
+								"VulnCheckDatabase": "",
+								"VulnCheckAllow": null,
+								"VulnCheckAllowSeverities": null,
+								"Licenses": false,
+								"LicensesAllow": null,
+								"LicensesDeny": null,
+								"ModulesDeny": null,
+								"ModulesDenyPseudoVersions": null,
+								"ModulesDenyExternalReplace": false,
+								"ModulesRequireToolchain": false,
+								"Debug": false,
+								"DebugPort": 0,
+								"SplitDebugSymbols": false,
+								"PGO": "",
+								"WorkDir": "",
+								"KeepFiles": null,
+								"DefaultProcess": "",
+								"GeneratePatterns": null,
+								"VetPatterns": null,
+								"VetFlags": null,
+								"TestPatterns": null,
+								"TestFlags": null,
+								"TestBinaryPatterns": ["./store"]
+							}
+						},
+						"internalParameters": {
+							"stack": "some-stack",
+							"commands": [
+								["go", "build", "-o", "some-output", "-buildmode", "pie", "-trimpath", "./some-target", "./other-target"]
+							]
+						},
+						"resolvedDependencies": [
+							{
+								"name": "workspace",
+								"uri": "git+https://github.com/some-org/some-repo@some-commit",
+								"digest": {"sha256": "some-workspace-sha", "gitCommit": "some-commit"}
+							},
+							{
+								"name": "go",
+								"annotations": {"version": "go1.22.4"}
+							}
+						]
+					},
+					"runDetails": {
+						"builder": {"id": "some-org/some-buildpack@some-version"}
+					}
+				}
+			}`))
+		})
+
+		context("when the provenance should be added as an image label", func() {
+			it.Before(func() {
+				parser.ParseCall.Returns.BuildConfiguration.ProvenanceLabel = true
+			})
+
+			it("adds the statement as a label", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "some-buildpack", "targets", "provenance.intoto.json"))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Labels).To(HaveKeyWithValue("io.paketo.go-build.provenance", MatchJSON(content)))
+				Expect(result.Launch.Labels["io.paketo.go-build.provenance"]).NotTo(ContainSubstring("\n"))
+			})
+
+			it("records the label in the targets layer metadata", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].Metadata).To(HaveKeyWithValue("provenance_label", result.Launch.Labels["io.paketo.go-build.provenance"]))
+			})
+
+			context("when the targets layer is reused", func() {
+				it.Before(func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					// Only the metadata of a launch layer is restored from the image
+					Expect(os.RemoveAll(filepath.Join(layersDir, "some-buildpack", "targets"))).To(Succeed())
+
+					content := fmt.Sprintf(`launch = true
+
+[metadata]
+  workspace_sha = %q
+  binaries = ["path/some-start-command", "path/another-start-command"]
+  provenance_label = '{"_type":"some-cached-statement"}'
+`, result.Layers[0].Metadata["workspace_sha"])
+					Expect(os.WriteFile(filepath.Join(layersDir, "some-buildpack", "targets.toml"), []byte(content), 0600)).To(Succeed())
+				})
+
+				it("adds the statement from the layer metadata as a label", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(buildProcess.ExecuteCall.CallCount).To(Equal(1))
+
+					Expect(result.Launch.Labels).To(Equal(map[string]string{
+						"io.paketo.go-build.provenance": `{"_type":"some-cached-statement"}`,
+					}))
+				})
+			})
+
+			context("when the reused targets layer has no label", func() {
+				it.Before(func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(os.RemoveAll(filepath.Join(layersDir, "some-buildpack", "targets"))).To(Succeed())
+
+					content := fmt.Sprintf(`launch = true
+
+[metadata]
+  workspace_sha = %q
+  binaries = ["path/some-start-command", "path/another-start-command"]
+`, result.Layers[0].Metadata["workspace_sha"])
+					Expect(os.WriteFile(filepath.Join(layersDir, "some-buildpack", "targets.toml"), []byte(content), 0600)).To(Succeed())
+				})
+
+				it("rebuilds the targets", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(buildProcess.ExecuteCall.CallCount).To(Equal(2))
+
+					Expect(result.Launch.Labels).To(HaveKeyWithValue("io.paketo.go-build.provenance", ContainSubstring(`"gitCommit":"some-commit"`)))
+				})
+			})
+		})
+
+		context("when the project metadata is missing", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(layersDir, "project-metadata.toml"))).To(Succeed())
+			})
+
+			it("only describes the workspace by its checksum", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "some-buildpack", "targets", "provenance.intoto.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`"name": "workspace",
+          "digest": {
+            "sha256": "some-workspace-sha"
+          }`))
+			})
+		})
+	})
+
+	context("when go vet and go test should be run", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.VetPatterns = []string{"./..."}
+			parser.ParseCall.Returns.BuildConfiguration.VetFlags = []string{"-printf=false"}
+			parser.ParseCall.Returns.BuildConfiguration.TestPatterns = []string{"./internal/..."}
+			parser.ParseCall.Returns.BuildConfiguration.TestFlags = []string{"-short"}
+		})
+
+		it("passes the settings to the build process", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			config := buildProcess.ExecuteCall.Receives.Config
+			Expect(config.VetPatterns).To(Equal([]string{"./..."}))
+			Expect(config.VetFlags).To(Equal([]string{"-printf=false"}))
+			Expect(config.TestPatterns).To(Equal([]string{"./internal/..."}))
+			Expect(config.TestFlags).To(Equal([]string{"-short"}))
+		})
+	})
+
+	context("when test binaries should be compiled", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.TestBinaryPatterns = []string{"./internal/..."}
+			buildProcess.CompileTestsCall.Returns.Binaries = []string{"tests/store.test", "tests/queue.test"}
+		})
+
+		it("adds a launch process for each test binary", func() {
+			result, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			config := buildProcess.CompileTestsCall.Receives.Config
+			Expect(config.TestBinaryPatterns).To(Equal([]string{"./internal/..."}))
+			Expect(config.TestBinaryOutput).To(Equal(filepath.Join(layersDir, "targets", "tests")))
+
+			targets := result.Layers[0]
+			Expect(targets.Metadata).To(HaveKeyWithValue("test_binaries", []string{"tests/store.test", "tests/queue.test"}))
+			Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(targets.Path))
+
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{
+					Type:    "some-start-command",
+					Command: "path/some-start-command",
+					Direct:  true,
+					Default: true,
+				},
+				{
+					Type:    "another-start-command",
+					Command: "path/another-start-command",
+					Direct:  true,
+				},
+				{
+					Type:    "test-store",
+					Command: "tests/store.test",
+					Direct:  true,
+				},
+				{
+					Type:    "test-queue",
+					Command: "tests/queue.test",
+					Direct:  true,
+				},
+			}))
+		})
+	})
+
+	context("when the binaries should be scanned for vulnerabilities", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.VulnCheck = true
+			parser.ParseCall.Returns.BuildConfiguration.VulnCheckDatabase = "/some/vulndb"
+			parser.ParseCall.Returns.BuildConfiguration.VulnCheckAllow = []string{"GO-2024-0001"}
+			parser.ParseCall.Returns.BuildConfiguration.VulnCheckAllowSeverities = []string{"LOW"}
+			parser.ParseCall.Returns.BuildConfiguration.TestBinaryPatterns = []string{"./internal/..."}
+			buildProcess.CompileTestsCall.Returns.Binaries = []string{"tests/store.test"}
+		})
+
+		it("scans the binaries and the test binaries", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Platform: packit.Platform{Path: "some-platform-path"},
+				Layers:   packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(scanner.ScanCall.CallCount).To(Equal(1))
+			Expect(scanner.ScanCall.Receives.Config).To(Equal(gobuild.VulnerabilityScanConfiguration{
+				Binaries:        []string{"path/some-start-command", "path/another-start-command", "tests/store.test"},
+				PlatformPath:    "some-platform-path",
+				Database:        "/some/vulndb",
+				Allow:           []string{"GO-2024-0001"},
+				AllowSeverities: []string{"LOW"},
+				Report:          filepath.Join(layersDir, "targets", "vulnerabilities.json"),
+			}))
+		})
+
+		it("rebuilds and rescans the targets when the database is updated", func() {
+			buildContext := packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Platform: packit.Platform{Path: "some-platform-path"},
+				Layers:   packit.Layers{Path: layersDir},
+			}
+
+			scanner.DatabaseVersionCall.Returns.String = "2024-01-01T00:00:00Z"
+			first, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(scanner.DatabaseVersionCall.Receives.Config).To(Equal(gobuild.VulnerabilityScanConfiguration{
+				PlatformPath: "some-platform-path",
+				Database:     "/some/vulndb",
+			}))
+
+			scanner.DatabaseVersionCall.Returns.String = "2024-02-01T00:00:00Z"
+			second, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(second.Layers[0].Metadata[gobuild.WorkspaceSHAKey]).NotTo(Equal(first.Layers[0].Metadata[gobuild.WorkspaceSHAKey]))
+		})
+	})
+
+	context("when the licenses should be collected", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.Licenses = true
+			parser.ParseCall.Returns.BuildConfiguration.LicensesAllow = []string{"MIT"}
+			parser.ParseCall.Returns.BuildConfiguration.LicensesDeny = []string{"GPL-3.0"}
+			parser.ParseCall.Returns.BuildConfiguration.WorkspaceUseModules = []string{"./some-module"}
+			Expect(os.MkdirAll(filepath.Join(workingDir, "some-module"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-module", "go.mod"), []byte("module example.com/some-module\n"), 0600)).To(Succeed())
+			parser.ParseCall.Returns.BuildConfiguration.TestBinaryPatterns = []string{"./internal/..."}
+			buildProcess.CompileTestsCall.Returns.Binaries = []string{"tests/store.test"}
+		})
+
+		it("collects the licenses of the binaries and the test binaries", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(collector.CollectCall.CallCount).To(Equal(1))
+			Expect(collector.CollectCall.Receives.Config).To(Equal(gobuild.LicenseCollectionConfiguration{
+				Binaries:   []string{"path/some-start-command", "path/another-start-command", "tests/store.test"},
+				Workspace:  workingDir,
+				Modules:    []string{workingDir, filepath.Join(workingDir, "some-module")},
+				GoModCache: filepath.Join(layersDir, "gomodcache"),
+				Allow:      []string{"MIT"},
+				Deny:       []string{"GPL-3.0"},
+				Manifest:   filepath.Join(layersDir, "targets", "licenses.json"),
+			}))
+		})
+	})
+
+	context("when files should be kept", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.KeepFiles = []string{"assets/*"}
+		})
+
+		it("passes them to the source remover", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sourceRemover.ClearCall.Receives.KeepFiles).To(Equal([]string{"assets/*"}))
+		})
+	})
+
+	context("when the build is in debug mode", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.Debug = true
+			parser.ParseCall.Returns.BuildConfiguration.DebugPort = 40000
+		})
+
+		it("keeps the source and adds processes that run the binaries under delve", func() {
+			result, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buildProcess.ExecuteCall.Receives.Config.Debug).To(BeTrue())
+			Expect(sourceRemover.ClearCall.CallCount).To(Equal(0))
+
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{
+					Type:    "some-start-command",
+					Command: "path/some-start-command",
+					Direct:  true,
+					Default: true,
+				},
+				{
+					Type:    "debug-some-start-command",
+					Command: "dlv",
+					Args: []string{
+						"exec",
+						"--headless",
+						"--listen", ":40000",
+						"--api-version", "2",
+						"--accept-multiclient",
+						"--continue",
+						"path/some-start-command",
+					},
+					Direct: true,
+				},
+				{
+					Type:    "another-start-command",
+					Command: "path/another-start-command",
+					Direct:  true,
+				},
+				{
+					Type:    "debug-another-start-command",
+					Command: "dlv",
+					Args: []string{
+						"exec",
+						"--headless",
+						"--listen", ":40000",
+						"--api-version", "2",
+						"--accept-multiclient",
+						"--continue",
+						"path/another-start-command",
+					},
+					Direct: true,
+				},
+			}))
+
+			Expect(logs.String()).To(ContainSubstring("Keeping the application source for debugging"))
+		})
+	})
+
+	context("when the debug symbols are split from the binaries", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.SplitDebugSymbols = true
+
+			buildContext = packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			}
+		})
+
+		it("builds the debug symbols into a cached layer that is not launched", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buildProcess.ExecuteCall.Receives.Config.DebugSymbolsOutput).To(Equal(filepath.Join(layersDir, "debug-symbols")))
+
+			Expect(result.Layers).To(HaveLen(4))
+			debugSymbols := result.Layers[3]
+			Expect(debugSymbols.Name).To(Equal("debug-symbols"))
+			Expect(debugSymbols.Path).To(Equal(filepath.Join(layersDir, "debug-symbols")))
+			Expect(debugSymbols.Build).To(BeFalse())
+			Expect(debugSymbols.Launch).To(BeFalse())
+			Expect(debugSymbols.Cache).To(BeTrue())
+			Expect(debugSymbols.Metadata).To(HaveKeyWithValue("workspace_sha", result.Layers[0].Metadata["workspace_sha"]))
+		})
+
+		context("when the binaries are scanned for vulnerabilities", func() {
+			it.Before(func() {
+				parser.ParseCall.Returns.BuildConfiguration.VulnCheck = true
+				buildProcess.ExecuteCall.Returns.Details.DebugSymbols = map[string]string{
+					"path/some-start-command": "debug-symbols/.build-id/ab/cdef.debug",
+				}
+			})
+
+			it("scans the unstripped binaries", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(scanner.ScanCall.Receives.Config.Binaries).To(Equal([]string{"path/some-start-command", "path/another-start-command"}))
+				Expect(scanner.ScanCall.Receives.Config.DebugSymbols).To(Equal(map[string]string{
+					"path/some-start-command": "debug-symbols/.build-id/ab/cdef.debug",
+				}))
+			})
+		})
+
+		context("when the targets layer was built from the same fingerprint", func() {
+			var fingerprint interface{}
+
+			it.Before(func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				fingerprint = result.Layers[0].Metadata["workspace_sha"]
+				content := fmt.Sprintf(`launch = true
+
+[metadata]
+  workspace_sha = %q
+  binaries = ["path/some-start-command", "path/another-start-command"]
+`, fingerprint)
+				Expect(os.WriteFile(filepath.Join(layersDir, "targets.toml"), []byte(content), 0600)).To(Succeed())
+			})
+
+			context("when the debug symbols are cached as well", func() {
+				it.Before(func() {
+					content := fmt.Sprintf(`cache = true
+
+[metadata]
+  workspace_sha = %q
+`, fingerprint)
+					Expect(os.WriteFile(filepath.Join(layersDir, "debug-symbols.toml"), []byte(content), 0600)).To(Succeed())
+				})
+
+				it("reuses both layers", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(buildProcess.ExecuteCall.CallCount).To(Equal(1))
+					Expect(result.Layers[3].Name).To(Equal("debug-symbols"))
+					Expect(result.Layers[3].Cache).To(BeTrue())
+				})
+			})
+
+			context("when the debug symbols are no longer cached", func() {
+				it("rebuilds the targets", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(buildProcess.ExecuteCall.CallCount).To(Equal(2))
+				})
+			})
+		})
+	})
+
+	context("when PGO profiles are found for the targets", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.PGO = "some-profile"
+			profiles.ResolveCall.Returns.ProfileSet = gobuild.ProfileSet{
+				Flag: filepath.Join(workingDir, "some-target", "default.pgo"),
+				Targets: map[string]string{
+					"some-target": filepath.Join(workingDir, "some-target", "default.pgo"),
+				},
+				Digest: "some-profiles-digest",
+			}
+		})
+
+		it("builds the targets with the profiles and reports where they were applied", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "some-platform-path"},
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(profiles.ResolveCall.Receives.Config).To(Equal(gobuild.ProfileConfiguration{
+				Workspace:    workingDir,
+				Targets:      []string{"some-target", "other-target"},
+				Flags:        []string{"some-flag", "other-flag"},
+				Profile:      "some-profile",
+				PlatformPath: "some-platform-path",
+			}))
+
+			Expect(buildProcess.ExecuteCall.Receives.Config.PGO).To(Equal(filepath.Join(workingDir, "some-target", "default.pgo")))
+
+			Expect(logs.String()).To(ContainSubstring("Profile-guided optimization"))
+			Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("some-target: applied %s", filepath.Join("some-target", "default.pgo"))))
+			Expect(logs.String()).To(ContainSubstring("other-target: not applied"))
+		})
+	})
+
+	context("when none of the targets are built with a PGO profile", func() {
+		it("does not report on profile-guided optimization", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(logs.String()).NotTo(ContainSubstring("Profile-guided optimization"))
+		})
+	})
+
+	context("when a module policy is configured", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go.mod"), []byte(`module example.com/app
+
+go 1.22
+
+require (
+	example.com/allowed v1.0.0
+	example.com/denied/sub v1.2.0
+	example.com/unstable v0.0.0-20240101000000-abcdefabcdef
+	example.com/stable v0.0.0-20240101000000-abcdefabcdef
+	example.com/local v0.0.0-00010101000000-000000000000
+	example.com/external v0.0.0-00010101000000-000000000000
+	example.com/forked v1.0.0
+)
+
+replace example.com/local => ./local
+
+replace example.com/external => ../external
+
+replace example.com/forked => example.com/denied/fork v1.0.1
+`), 0600)).To(Succeed())
+
+			parser.ParseCall.Returns.BuildConfiguration.ModulesDeny = []string{"example.com/denied"}
+			parser.ParseCall.Returns.BuildConfiguration.ModulesDenyPseudoVersions = []string{"example.com/unstable", "example.com/local"}
+			parser.ParseCall.Returns.BuildConfiguration.ModulesDenyExternalReplace = true
+			parser.ParseCall.Returns.BuildConfiguration.ModulesRequireToolchain = true
+		})
+
+		it("fails the build with every violation of the policy", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).To(MatchError(strings.Join([]string{
+				"module policy violated by the following modules:",
+				"  example.com/app: go.mod does not list a toolchain",
+				"  example.com/external => ../external: replaced by a local path outside the application",
+				`  example.com/denied/sub@v1.2.0: matches the denied module pattern "example.com/denied"`,
+				`  example.com/unstable@v0.0.0-20240101000000-abcdefabcdef: uses a pseudo-version, which is denied for "example.com/unstable"`,
+				`  example.com/denied/fork@v1.0.1: matches the denied module pattern "example.com/denied"`,
+			}, "\n")))
+
+			Expect(buildProcess.ExecuteCall.CallCount).To(Equal(0))
+		})
+
+		context("when the module is built from a subdirectory of the application", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "app"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "app", "go.mod"), []byte(`module example.com/app
+
+go 1.22
+
+toolchain go1.22.4
+
+require example.com/shared v0.0.0-00010101000000-000000000000
+
+replace example.com/shared => ../shared
+`), 0600)).To(Succeed())
+
+				parser.ParseCall.Returns.BuildConfiguration.WorkDir = "app"
+			})
+
+			it("allows local replacements within the application", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		context("when the workspace modules violate the policy", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n\ntoolchain go1.22.4\n"), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "tools"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "tools", "go.mod"), []byte("module example.com/tools\n\ngo 1.22\n\nrequire example.com/denied v1.0.0\n"), 0600)).To(Succeed())
+
+				parser.ParseCall.Returns.BuildConfiguration.WorkspaceUseModules = []string{"tools"}
+			})
+
+			it("fails the build", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError(strings.Join([]string{
+					"module policy violated by the following modules:",
+					"  example.com/tools: go.mod does not list a toolchain",
+					`  example.com/denied@v1.0.0: matches the denied module pattern "example.com/denied"`,
+				}, "\n")))
+			})
+		})
+
+		context("when the workspace modules are discovered", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n\ntoolchain go1.22.4\n"), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "services", "api"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "services", "api", "go.mod"), []byte("module example.com/api\n\ngo 1.22\n\ntoolchain go1.22.4\n\nrequire example.com/denied v1.0.0\n"), 0600)).To(Succeed())
+
+				parser.ParseCall.Returns.BuildConfiguration.WorkspaceUseModules = []string{"auto"}
+			})
+
+			it("checks every discovered module", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError(strings.Join([]string{
+					"module policy violated by the following modules:",
+					`  example.com/denied@v1.0.0: matches the denied module pattern "example.com/denied"`,
+				}, "\n")))
+			})
+		})
+
+		context("when a target is in a nested module", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n\ntoolchain go1.22.4\n"), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "tools", "gen"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "tools", "go.mod"), []byte("module example.com/tools\n\ngo 1.22\n\nrequire example.com/unstable v0.0.0-20240101000000-abcdefabcdef\n"), 0600)).To(Succeed())
+
+				parser.ParseCall.Returns.BuildConfiguration.Targets = []string{".", "./tools/gen"}
+			})
+
+			it("checks the nested module", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError(strings.Join([]string{
+					"module policy violated by the following modules:",
+					"  example.com/tools: go.mod does not list a toolchain",
+					`  example.com/unstable@v0.0.0-20240101000000-abcdefabcdef: uses a pseudo-version, which is denied for "example.com/unstable"`,
+				}, "\n")))
+			})
+		})
+	})
+
+	context("when a default process is configured", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.DefaultProcess = "another-start-command"
+		})
+
+		it("makes that process the default", func() {
+			result, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{
+					Type:    "some-start-command",
+					Command: "path/some-start-command",
+					Direct:  true,
+				},
+				{
+					Type:    "another-start-command",
+					Command: "path/another-start-command",
+					Direct:  true,
+					Default: true,
+				},
+			}))
+		})
+	})
+
+	context("when the application has module checksum files", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go.sum"), []byte("some-sums"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "go.work.sum"), []byte("some-work-sums"), 0600)).To(Succeed())
+
+			calculator.SumCall.Stub = func(paths ...string) (string, error) {
+				if len(paths) == 2 {
+					return "some-module-sums-sha", nil
+				}
+				return "some-workspace-sha", nil
+			}
+
+			Expect(os.MkdirAll(filepath.Join(layersDir, "gomodcache", "cache"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(layersDir, "gomodcache", "cache", "some-module"), nil, 0600)).To(Succeed())
+
+			buildContext = packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			}
+		})
+
+		context("when the checksum files match the cached module layer", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "gomodcache.toml"), []byte(`cache = true
+
+[metadata]
+  module_sums_sha = "some-module-sums-sha"
+`), 0600)).To(Succeed())
+			})
+
+			it("reuses the module cache", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				gomodcache := result.Layers[2]
+				Expect(gomodcache.Cache).To(BeTrue())
+				Expect(gomodcache.Metadata).To(Equal(map[string]interface{}{
+					"module_sums_sha": "some-module-sums-sha",
+				}))
+
+				Expect(filepath.Join(layersDir, "gomodcache", "cache", "some-module")).To(BeAnExistingFile())
+			})
+		})
+
+		context("when the checksum files have changed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "gomodcache.toml"), []byte(`cache = true
+
+[metadata]
+  module_sums_sha = "some-previous-module-sums-sha"
+`), 0600)).To(Succeed())
+			})
+
+			it("clears the module cache", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				gomodcache := result.Layers[2]
+				Expect(gomodcache.Cache).To(BeTrue())
+				Expect(gomodcache.Metadata).To(Equal(map[string]interface{}{
+					"module_sums_sha": "some-module-sums-sha",
+				}))
+
+				Expect(filepath.Join(layersDir, "gomodcache", "cache", "some-module")).NotTo(BeAnExistingFile())
+				Expect(filepath.Join(layersDir, "gomodcache")).To(BeADirectory())
+			})
+		})
+	})
+
+	context("when the modules of the build have their own checksum files", func() {
+		var (
+			moduleSums   [][]string
+			buildContext packit.BuildContext
+		)
+
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "go.sum"), []byte("some-sums"), 0600)).To(Succeed())
+
+			moduleSums = nil
+			calculator.SumCall.Stub = func(paths ...string) (string, error) {
+				if filepath.Base(paths[0]) == "go.sum" {
+					moduleSums = append(moduleSums, paths)
+				}
+				return "some-sha", nil
+			}
+
+			buildContext = packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			}
+		})
+
+		context("when a target is in a nested module", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "tools", "gen"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "tools", "go.mod"), []byte("module example.com/tools\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "tools", "go.sum"), []byte("some-tools-sums"), 0600)).To(Succeed())
+
+				parser.ParseCall.Returns.BuildConfiguration.Targets = []string{".", "./tools/gen"}
+			})
+
+			it("checksums the go.sum of that module", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(moduleSums).To(Equal([][]string{{
+					filepath.Join(workingDir, "go.sum"),
+					filepath.Join(workingDir, "tools", "go.sum"),
+				}}))
+			})
+		})
+
+		context("when the workspace modules are discovered", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "services", "api"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "services", "api", "go.mod"), []byte("module example.com/api\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "services", "api", "go.sum"), []byte("some-api-sums"), 0600)).To(Succeed())
+
+				parser.ParseCall.Returns.BuildConfiguration.WorkspaceUseModules = []string{"auto"}
+			})
+
+			it("checksums the go.sum of every workspace module", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(moduleSums).To(Equal([][]string{{
+					filepath.Join(workingDir, "go.sum"),
+					filepath.Join(workingDir, "services", "api", "go.sum"),
+				}}))
+			})
+		})
+	})
+
+	context("when the build uses service bindings", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.Offline = true
+
+			bindings.ResolveCall.Stub = func(typ, provider, platformDir string) ([]servicebindings.Binding, error) {
+				switch typ {
+				case "git-credentials":
+					return []servicebindings.Binding{{Name: "credentials", Type: typ, Path: "some-credentials-path"}}, nil
+				case "go-module-mirror":
+					return []servicebindings.Binding{{Name: "mirror", Type: typ, Path: "some-mirror-path"}}, nil
+				}
+
+				return nil, nil
+			}
+
+			calculator.SumCall.Stub = func(paths ...string) (string, error) {
+				return strings.Join(paths, ","), nil
+			}
+
+			buildContext = packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "some-platform-path"},
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			}
+		})
+
+		it("includes the contents of the credential and module mirror bindings in the fingerprint", func() {
+			first, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(bindings.ResolveCall.Receives.PlatformDir).To(Equal("some-platform-path"))
+
+			calculator.SumCall.Stub = func(paths ...string) (string, error) {
+				if slices.Contains(paths, "some-mirror-path") {
+					return "some-other-bindings-sha", nil
+				}
+
+				return strings.Join(paths, ","), nil
+			}
+
+			second, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(second.Layers[0].Metadata[gobuild.WorkspaceSHAKey]).NotTo(Equal(first.Layers[0].Metadata[gobuild.WorkspaceSHAKey]))
+		})
+	})
+
+	context("when the targets layer was built from the same fingerprint", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			buildContext = packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Stack:      "some-stack",
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				Layers: packit.Layers{Path: layersDir},
+			}
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			content := fmt.Sprintf(`launch = true
+
+[metadata]
+  workspace_sha = %q
+  binaries = ["path/some-start-command", "path/another-start-command"]
+`, result.Layers[0].Metadata["workspace_sha"])
+			Expect(os.WriteFile(filepath.Join(layersDir, "targets.toml"), []byte(content), 0600)).To(Succeed())
+
+			logs.Reset()
+		})
+
+		it("reuses the cached layer and its processes", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			targets := result.Layers[0]
+			Expect(targets.Name).To(Equal("targets"))
+			Expect(targets.Launch).To(BeTrue())
+			Expect(targets.Metadata).To(HaveKeyWithValue("binaries", []interface{}{"path/some-start-command", "path/another-start-command"}))
+
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{
+					Type:    "some-start-command",
+					Command: "path/some-start-command",
+					Direct:  true,
+					Default: true,
+				},
+				{
+					Type:    "another-start-command",
+					Command: "path/another-start-command",
+					Direct:  true,
+				},
+			}))
+
+			Expect(buildProcess.ExecuteCall.CallCount).To(Equal(1))
+			Expect(pathManager.SetupCall.CallCount).To(Equal(1))
+			Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(1))
+			Expect(sourceRemover.ClearCall.CallCount).To(Equal(2))
+
+			Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("Reusing cached layer %s", filepath.Join(layersDir, "targets"))))
+		})
+
+		context("when the cached layer contains test binaries", func() {
+			it.Before(func() {
+				content, err := os.ReadFile(filepath.Join(layersDir, "targets.toml"))
+				Expect(err).NotTo(HaveOccurred())
+
+				content = append(content, []byte("  test_binaries = [\"tests/store.test\"]\n")...)
+				Expect(os.WriteFile(filepath.Join(layersDir, "targets.toml"), content, 0600)).To(Succeed())
+			})
+
+			it("reuses the test binary processes", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes).To(ContainElement(packit.Process{
+					Type:    "test-store",
+					Command: "tests/store.test",
+					Direct:  true,
+				}))
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(1))
+			})
+		})
+
+		context("when the build configuration has changed", func() {
+			it.Before(func() {
+				parser.ParseCall.Returns.BuildConfiguration.Flags = []string{"some-other-flag"}
+			})
+
+			it("rebuilds the targets", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(2))
+				Expect(logs.String()).NotTo(ContainSubstring("Reusing cached layer"))
+			})
+		})
+
+		context("when the workspace has changed", func() {
+			it.Before(func() {
+				calculator.SumCall.Returns.String = "some-other-workspace-sha"
+			})
+
+			it("rebuilds the targets", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(2))
+			})
+		})
+
+		context("when the go toolchain has changed", func() {
+			it.Before(func() {
+				toolchain.VersionCall.Returns.String = "go1.23.0"
+			})
+
+			it("rebuilds the targets", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(2))
+			})
+		})
+
+		context("when the stack has changed", func() {
+			it.Before(func() {
+				buildContext.Stack = "some-other-stack"
+			})
+
+			it("rebuilds the targets", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(2))
+			})
+		})
+
+		context("when a variable of the build environment that affects the binaries has changed", func() {
+			it.Before(func() {
+				t.Setenv("GOAMD64", "v3")
+			})
+
+			it("rebuilds the targets", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(2))
+			})
+		})
+
+		context("when an unrelated variable of the build environment has changed", func() {
+			it.Before(func() {
+				t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/secrets/some-key.json")
+			})
+
+			it("reuses the cached layer", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(1))
+			})
+		})
+
+		context("when a private module credential binding has been added", func() {
+			it.Before(func() {
+				bindings.ResolveCall.Stub = func(typ, provider, platformDir string) ([]servicebindings.Binding, error) {
+					if typ != "netrc" {
+						return nil, nil
+					}
+
+					return []servicebindings.Binding{{Name: "some-binding", Type: "netrc", Path: "some-binding-path"}}, nil
+				}
+			})
+
+			it("rebuilds the targets", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(2))
+			})
+		})
+
+		context("when the PGO profiles have changed", func() {
+			it.Before(func() {
+				profiles.ResolveCall.Returns.ProfileSet.Digest = "some-other-profiles-digest"
+			})
+
+			it("rebuilds the targets", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(2))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the targets layer cannot be retrieved", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "targets.toml"), nil, 0000)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse layer content metadata")))
+				Expect(err).To(MatchError(ContainSubstring("permission denied")))
+			})
+		})
+
+		context("when the gocache layer cannot be retrieved", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "gocache.toml"), nil, 0000)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse layer content metadata")))
+				Expect(err).To(MatchError(ContainSubstring("permission denied")))
+			})
+		})
+
+		context("when the workspace checksum cannot be calculated", func() {
+			it.Before(func() {
+				calculator.SumCall.Returns.Error = errors.New("failed to calculate checksum")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to calculate checksum"))
+			})
+		})
+
+		context("when the go toolchain version cannot be determined", func() {
+			it.Before(func() {
+				toolchain.VersionCall.Returns.Error = errors.New("failed to determine go version")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to determine go version"))
+			})
+		})
+
+		context("when the gomodcache layer cannot be retrieved", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "gomodcache.toml"), nil, 0000)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse layer content metadata")))
+				Expect(err).To(MatchError(ContainSubstring("permission denied")))
+			})
+		})
+
+		context("when the module checksum files cannot be checksummed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go.sum"), nil, 0600)).To(Succeed())
+				calculator.SumCall.Stub = func(paths ...string) (string, error) {
+					if paths[0] == filepath.Join(workingDir, "go.sum") {
+						return "", errors.New("failed to calculate module sums checksum")
+					}
+					return "some-workspace-sha", nil
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to calculate module sums checksum"))
+			})
+		})
+
+		context("when the go path cannot be setup", func() {
+			it.Before(func() {
+				pathManager.SetupCall.Returns.Err = errors.New("failed to setup go path")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to setup go path"))
+			})
+		})
+
+		context("when the build process fails", func() {
+			it.Before(func() {
+				buildProcess.ExecuteCall.Returns.Err = errors.New("failed to execute build process")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to execute build process"))
+			})
+		})
+
+		context("when the test binaries cannot be compiled", func() {
+			it.Before(func() {
+				parser.ParseCall.Returns.BuildConfiguration.TestBinaryPatterns = []string{"./..."}
+				buildProcess.CompileTestsCall.Returns.Err = errors.New("failed to compile test binaries")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to compile test binaries"))
+			})
+		})
+
+		context("when the go path cannot be torn down", func() {
+			it.Before(func() {
+				pathManager.TeardownCall.Returns.Error = errors.New("failed to teardown go path")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to teardown go path"))
+			})
+		})
+
+		context("when the vulnerability database version cannot be read", func() {
+			it.Before(func() {
+				parser.ParseCall.Returns.BuildConfiguration.VulnCheck = true
+				scanner.DatabaseVersionCall.Returns.Error = errors.New("failed to read vulnerability database index")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to read vulnerability database index"))
+				Expect(buildProcess.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the vulnerability scan fails", func() {
+			it.Before(func() {
+				parser.ParseCall.Returns.BuildConfiguration.VulnCheck = true
+				scanner.ScanCall.Returns.Error = errors.New("vulnerability policy violated")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("vulnerability policy violated"))
+			})
+		})
+
+		context("when the license policy is violated", func() {
+			it.Before(func() {
+				parser.ParseCall.Returns.BuildConfiguration.Licenses = true
+				collector.CollectCall.Returns.Error = errors.New("license policy violated")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("license policy violated"))
+			})
+		})
+
+		context("when the service bindings cannot be resolved", func() {
+			it.Before(func() {
+				bindings.ResolveCall.Returns.Error = errors.New("failed to resolve bindings")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to resolve git-credentials bindings: failed to resolve bindings"))
+			})
+		})
+
+		context("when the PGO profiles cannot be resolved", func() {
+			it.Before(func() {
+				profiles.ResolveCall.Returns.Error = errors.New("failed to resolve profiles")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to resolve profiles"))
+			})
+		})
+
+		context("when the go.mod file cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "go.mod"), []byte("%%%"), 0600)).To(Succeed())
+				parser.ParseCall.Returns.BuildConfiguration.ModulesRequireToolchain = true
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse go.mod:")))
+			})
+		})
+
+		context("when the source cannot be cleared", func() {
+			it.Before(func() {
+				sourceRemover.ClearCall.Returns.Error = errors.New("failed to remove source")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("failed to remove source"))
+			})
+		})
+
+		context("when an SBOM cannot be generated", func() {
+			it.Before(func() {
+				sbomGenerator.GenerateCall.Returns.Error = errors.New("sbom generation error")
+			})
+			it("fails the build and returns the error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "io.paketo.stacks.tiny",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError("sbom generation error"))
+			})
+		})
+
+		context("when a requested SBOM format is invalid", func() {
+			it("fails the build and returns the error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "io.paketo.stacks.tiny",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:        "Some Buildpack",
+						Version:     "some-version",
+						SBOMFormats: []string{"invalid-format"},
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError(`unsupported SBOM format: 'invalid-format'`))
+			})
+		})
+
+		context("when the default process does not match a binary", func() {
+			it.Before(func() {
+				parser.ParseCall.Returns.BuildConfiguration.DefaultProcess = "some-missing-command"
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Stack:      "some-stack",
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
+					Layers: packit.Layers{Path: layersDir},
+				})
+				Expect(err).To(MatchError(`default process "some-missing-command" does not match any of the built binaries`))
 			})
 		})
 
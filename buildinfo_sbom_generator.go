@@ -0,0 +1,193 @@
+package gobuild
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/anchore/packageurl-go"
+	"github.com/anchore/syft/syft/artifact"
+	"github.com/anchore/syft/syft/cpe"
+	"github.com/anchore/syft/syft/file"
+	"github.com/anchore/syft/syft/pkg"
+	syftsbom "github.com/anchore/syft/syft/sbom"
+	"github.com/anchore/syft/syft/source"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+)
+
+const buildInfoCatalogerName = "go-build-buildinfo"
+
+// BuildInfoSBOMGenerator generates an SBOM from the build information that
+// the go command embeds in every binary, rather than by scanning the files.
+// The modules are reported exactly as they were compiled, including their
+// replacements, and every binary gets its own tree of components.
+type BuildInfoSBOMGenerator struct{}
+
+func NewBuildInfoSBOMGenerator() BuildInfoSBOMGenerator {
+	return BuildInfoSBOMGenerator{}
+}
+
+func (g BuildInfoSBOMGenerator) Generate(dir string) (sbom.SBOM, error) {
+	packages := pkg.NewCollection()
+	var relationships []artifact.Relationship
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := buildinfo.ReadFile(path)
+		if err != nil {
+			// Files that are not Go binaries have no build information
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		binaryPackages, binaryRelationships, err := buildInfoPackages(info, file.NewLocation(filepath.ToSlash(rel)))
+		if err != nil {
+			return fmt.Errorf("failed to describe build information of %s: %w", rel, err)
+		}
+
+		packages.Add(binaryPackages...)
+		relationships = append(relationships, binaryRelationships...)
+
+		return nil
+	})
+	if err != nil {
+		return sbom.SBOM{}, fmt.Errorf("failed to read build information: %w", err)
+	}
+
+	return sbom.NewSBOM(syftsbom.SBOM{
+		Artifacts: syftsbom.Artifacts{
+			Packages: packages,
+		},
+		Relationships: relationships,
+		Source: source.Description{
+			Metadata: source.DirectoryMetadata{
+				Path: dir,
+			},
+		},
+	}), nil
+}
+
+// buildInfoPackages returns the main module of a binary along with the
+// standard library and the modules it depends on. The dependencies are
+// related to the main module so that they form a tree for each binary.
+func buildInfoPackages(info *buildinfo.BuildInfo, location file.Location) ([]pkg.Package, []artifact.Relationship, error) {
+	var settings pkg.KeyValues
+	for _, setting := range info.Settings {
+		settings = append(settings, pkg.KeyValue{Key: setting.Key, Value: setting.Value})
+	}
+
+	architecture, _ := settings.Get("GOARCH")
+
+	main := newModulePackage(info.Main, location, pkg.GolangBinaryBuildinfoEntry{
+		BuildSettings:     settings,
+		GoCompiledVersion: info.GoVersion,
+		Architecture:      architecture,
+		MainModule:        info.Main.Path,
+	})
+
+	stdlib, err := newStandardLibraryPackage(info.GoVersion, location)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	packages := []pkg.Package{main, stdlib}
+	for _, dependency := range info.Deps {
+		packages = append(packages, newModulePackage(*dependency, location, pkg.GolangBinaryBuildinfoEntry{
+			GoCompiledVersion: info.GoVersion,
+			Architecture:      architecture,
+			MainModule:        info.Main.Path,
+		}))
+	}
+
+	var relationships []artifact.Relationship
+	for _, dependency := range packages[1:] {
+		relationships = append(relationships, artifact.Relationship{
+			From: dependency,
+			To:   main,
+			Type: artifact.DependencyOfRelationship,
+		})
+	}
+
+	return packages, relationships, nil
+}
+
+// newModulePackage describes the module that was compiled into the binary,
+// which is the replacement when the module has been replaced.
+func newModulePackage(module debug.Module, location file.Location, metadata pkg.GolangBinaryBuildinfoEntry) pkg.Package {
+	compiled := module
+	if module.Replace != nil {
+		compiled = *module.Replace
+	}
+
+	version := compiled.Version
+	if version == "" {
+		// Modules that are replaced by a local directory and the main module
+		// of a build without version control information have no version
+		version = "(devel)"
+	}
+
+	metadata.H1Digest = compiled.Sum
+
+	modulePackage := pkg.Package{
+		Name:      compiled.Path,
+		Version:   version,
+		FoundBy:   buildInfoCatalogerName,
+		Locations: file.NewLocationSet(location),
+		Language:  pkg.Go,
+		Type:      pkg.GoModulePkg,
+		PURL:      modulePURL(compiled.Path, version),
+		Metadata:  metadata,
+	}
+	modulePackage.SetID()
+
+	return modulePackage
+}
+
+func newStandardLibraryPackage(goVersion string, location file.Location) (pkg.Package, error) {
+	// Versions such as "go1.22.4 X:boringcrypto" list the experiments after
+	// the version itself
+	version, _, _ := strings.Cut(goVersion, " ")
+
+	stdlibCPE, err := cpe.New(fmt.Sprintf("cpe:2.3:a:golang:go:%s:-:*:*:*:*:*:*", strings.TrimPrefix(version, "go")), cpe.GeneratedSource)
+	if err != nil {
+		return pkg.Package{}, fmt.Errorf("failed to create CPE of the standard library: %w", err)
+	}
+
+	stdlib := pkg.Package{
+		Name:      "stdlib",
+		Version:   version,
+		FoundBy:   buildInfoCatalogerName,
+		Locations: file.NewLocationSet(location),
+		Language:  pkg.Go,
+		Type:      pkg.GoModulePkg,
+		PURL:      modulePURL("stdlib", strings.TrimPrefix(version, "go")),
+		CPEs:      []cpe.CPE{stdlibCPE},
+		Metadata: pkg.GolangBinaryBuildinfoEntry{
+			GoCompiledVersion: goVersion,
+		},
+	}
+	stdlib.SetID()
+
+	return stdlib, nil
+}
+
+func modulePURL(modulePath, version string) string {
+	namespace, name := path.Split(modulePath)
+
+	return packageurl.NewPackageURL(packageurl.TypeGolang, strings.TrimSuffix(namespace, "/"), name, version, nil, "").ToString()
+}
@@ -0,0 +1,214 @@
+package gobuild_test
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	gobuild "github.com/paketo-buildpacks/go-build"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBuildInfoSBOMGenerator(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		binDir    string
+		buildInfo *debug.BuildInfo
+
+		generator gobuild.BuildInfoSBOMGenerator
+	)
+
+	type syftArtifact struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Version  string `json:"version"`
+		Type     string `json:"type"`
+		PURL     string `json:"purl"`
+		Metadata struct {
+			GoBuildSettings []struct {
+				Key   string `json:"key"`
+				Value string `json:"value"`
+			} `json:"goBuildSettings"`
+			GoCompiledVersion string `json:"goCompiledVersion"`
+			Architecture      string `json:"architecture"`
+			MainModule        string `json:"mainModule"`
+		} `json:"metadata"`
+		Locations []struct {
+			Path string `json:"path"`
+		} `json:"locations"`
+	}
+
+	type syftDocument struct {
+		Artifacts             []syftArtifact `json:"artifacts"`
+		ArtifactRelationships []struct {
+			Parent string `json:"parent"`
+			Child  string `json:"child"`
+			Type   string `json:"type"`
+		} `json:"artifactRelationships"`
+	}
+
+	generate := func() syftDocument {
+		bom, err := generator.Generate(binDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		formatter, err := bom.InFormats(sbom.SyftFormat)
+		Expect(err).NotTo(HaveOccurred())
+
+		formats := formatter.Formats()
+		Expect(formats).To(HaveLen(1))
+
+		content, err := io.ReadAll(formats[0].Content)
+		Expect(err).NotTo(HaveOccurred())
+
+		var document syftDocument
+		Expect(json.Unmarshal(content, &document)).To(Succeed())
+
+		return document
+	}
+
+	it.Before(func() {
+		var err error
+		binDir, err = os.MkdirTemp("", "bin")
+		Expect(err).NotTo(HaveOccurred())
+
+		// The test binary is a Go binary with build information of its own
+		executable, err := os.Executable()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fs.Copy(executable, filepath.Join(binDir, "some-binary"))).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(binDir, "some-script"), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+
+		var ok bool
+		buildInfo, ok = debug.ReadBuildInfo()
+		Expect(ok).To(BeTrue())
+
+		generator = gobuild.NewBuildInfoSBOMGenerator()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(binDir)).To(Succeed())
+	})
+
+	it("describes the modules compiled into the binaries", func() {
+		document := generate()
+
+		artifacts := map[string]syftArtifact{}
+		for _, artifact := range document.Artifacts {
+			Expect(artifact.Locations).To(HaveLen(1))
+			Expect(artifact.Locations[0].Path).To(Equal("some-binary"))
+			Expect(artifact.Type).To(Equal("go-module"))
+			artifacts[artifact.Name] = artifact
+		}
+		Expect(artifacts).To(HaveLen(len(buildInfo.Deps) + 2))
+
+		main := artifacts[buildInfo.Main.Path]
+		Expect(main.Metadata.MainModule).To(Equal(buildInfo.Main.Path))
+		Expect(main.Metadata.GoCompiledVersion).To(Equal(buildInfo.GoVersion))
+		Expect(main.Metadata.GoBuildSettings).NotTo(BeEmpty())
+		for _, setting := range main.Metadata.GoBuildSettings {
+			if setting.Key == "GOARCH" {
+				Expect(main.Metadata.Architecture).To(Equal(setting.Value))
+			}
+		}
+
+		stdlib := artifacts["stdlib"]
+		Expect(stdlib.Version).To(Equal(strings.Fields(buildInfo.GoVersion)[0]))
+		Expect(stdlib.PURL).To(Equal("pkg:golang/stdlib@" + strings.TrimPrefix(stdlib.Version, "go")))
+
+		for _, dependency := range buildInfo.Deps {
+			compiled := dependency
+			if dependency.Replace != nil {
+				compiled = dependency.Replace
+			}
+
+			artifact, ok := artifacts[compiled.Path]
+			Expect(ok).To(BeTrue(), compiled.Path)
+			if compiled.Version != "" {
+				Expect(artifact.Version).To(Equal(compiled.Version))
+			} else {
+				Expect(artifact.Version).To(Equal("(devel)"))
+			}
+			Expect(artifact.PURL).To(HavePrefix("pkg:golang/"))
+			Expect(artifact.Metadata.MainModule).To(Equal(buildInfo.Main.Path))
+		}
+
+		gomega := artifacts["github.com/onsi/gomega"]
+		Expect(gomega.PURL).To(Equal("pkg:golang/github.com/onsi/gomega@" + gomega.Version))
+
+		Expect(document.ArtifactRelationships).To(HaveLen(len(buildInfo.Deps) + 1))
+		for _, relationship := range document.ArtifactRelationships {
+			Expect(relationship.Type).To(Equal("dependency-of"))
+			Expect(relationship.Child).To(Equal(main.ID))
+		}
+	})
+
+	context("when there are several binaries", func() {
+		it.Before(func() {
+			Expect(fs.Copy(filepath.Join(binDir, "some-binary"), filepath.Join(binDir, "other-binary"))).To(Succeed())
+		})
+
+		it("gives each binary its own components", func() {
+			document := generate()
+
+			binaries := map[string]int{}
+			for _, artifact := range document.Artifacts {
+				binaries[artifact.Locations[0].Path]++
+			}
+
+			Expect(binaries).To(Equal(map[string]int{
+				"some-binary":  len(buildInfo.Deps) + 2,
+				"other-binary": len(buildInfo.Deps) + 2,
+			}))
+			Expect(document.ArtifactRelationships).To(HaveLen(2 * (len(buildInfo.Deps) + 1)))
+		})
+	})
+
+	context("when binaries are in subdirectories", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(binDir, "tests"), os.ModePerm)).To(Succeed())
+			Expect(fs.Copy(filepath.Join(binDir, "some-binary"), filepath.Join(binDir, "tests", "store.test"))).To(Succeed())
+		})
+
+		it("locates them relative to the directory", func() {
+			document := generate()
+
+			binaries := map[string]int{}
+			for _, artifact := range document.Artifacts {
+				binaries[artifact.Locations[0].Path]++
+			}
+
+			Expect(binaries).To(Equal(map[string]int{
+				"some-binary":      len(buildInfo.Deps) + 2,
+				"tests/store.test": len(buildInfo.Deps) + 2,
+			}))
+		})
+	})
+
+	context("when there are no Go binaries", func() {
+		it.Before(func() {
+			Expect(os.Remove(filepath.Join(binDir, "some-binary"))).To(Succeed())
+		})
+
+		it("returns an empty SBOM", func() {
+			document := generate()
+			Expect(document.Artifacts).To(BeEmpty())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the directory does not exist", func() {
+			it("returns an error", func() {
+				_, err := generator.Generate(filepath.Join(binDir, "missing"))
+				Expect(err).To(MatchError(ContainSubstring("failed to read build information:")))
+			})
+		})
+	})
+}
@@ -1,7 +1,14 @@
 package gobuild
 
 const (
-	TargetsLayerName = "targets"
-	GoCacheLayerName = "gocache"
-	WorkspaceSHAKey  = "workspace_sha"
+	TargetsLayerName      = "targets"
+	GoCacheLayerName      = "gocache"
+	GoModCacheLayerName   = "gomodcache"
+	DebugSymbolsLayerName = "debug-symbols"
+	WorkspaceSHAKey       = "workspace_sha"
+	BinariesKey           = "binaries"
+	TestBinariesKey       = "test_binaries"
+	ReproducibilityKey    = "reproducibility"
+	ModuleSumsSHAKey      = "module_sums_sha"
+	ProvenanceLabelKey    = "provenance_label"
 )
@@ -0,0 +1,92 @@
+package gobuild
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// defaultDebugPort is the port that Delve listens on when BP_GO_DEBUG_PORT is
+// not set. It is the port that Delve itself defaults to.
+const defaultDebugPort = 2345
+
+// debugGCFlags disables the optimizations and inlining that keep a debugger
+// from stepping through the code and inspecting variables.
+const debugGCFlags = "-gcflags=all=-N -l"
+
+// debugFlags replaces any -gcflags with the flags that disable optimizations
+// and removes the linker flags that strip the binaries.
+func debugFlags(flags []string) []string {
+	return unstrippedFlags(mergeFlags(flags, []string{debugGCFlags}))
+}
+
+// unstrippedFlags removes the linker flags that strip the symbol table and the
+// DWARF debugging information from the binaries.
+func unstrippedFlags(flags []string) []string {
+	flags = slices.Clone(flags)
+	for i := 0; i < len(flags); i++ {
+		name, ok := flagName(flags[i])
+		if !ok || name != "ldflags" {
+			continue
+		}
+
+		index, prefix, ok := flagValue(flags, i)
+		if !ok {
+			break
+		}
+
+		var kept []string
+		for _, field := range strings.Fields(strings.TrimPrefix(flags[index], prefix)) {
+			if !isStripFlag(field) {
+				kept = append(kept, field)
+			}
+		}
+
+		if len(kept) > 0 {
+			flags[index] = prefix + strings.Join(kept, " ")
+			break
+		}
+
+		return slices.Delete(flags, i, index+1)
+	}
+
+	return flags
+}
+
+func isStripFlag(flag string) bool {
+	name, value, found := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+	if name != "s" && name != "w" {
+		return false
+	}
+
+	if !found {
+		return true
+	}
+
+	strip, err := strconv.ParseBool(value)
+	return err != nil || strip
+}
+
+// debugProcess returns the process that starts the binary under a headless
+// Delve server. The server accepts several clients and lets the binary run
+// until a client attaches, so the process behaves like the binary until then.
+func debugProcess(binary string, port int) packit.Process {
+	return packit.Process{
+		Type:    fmt.Sprintf("debug-%s", filepath.Base(binary)),
+		Command: "dlv",
+		Args: []string{
+			"exec",
+			"--headless",
+			"--listen", fmt.Sprintf(":%d", port),
+			"--api-version", "2",
+			"--accept-multiclient",
+			"--continue",
+			binary,
+		},
+		Direct: true,
+	}
+}
@@ -0,0 +1,165 @@
+package gobuild
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// buildIDSize is the size in bytes of the GNU build IDs given to the binaries
+// whose debug symbols are split from them, which matches the size of the
+// SHA-1 based build IDs of other toolchains.
+const buildIDSize = 20
+
+// buildIDDirectory is the directory of the debug symbols layer that holds the
+// unstripped binaries, laid out like the debug file directories that gdb,
+// Delve and debuginfod servers look up separate debug information in.
+const buildIDDirectory = ".build-id"
+
+// placeholderBuildID reserves room for the build ID in the unstripped
+// binaries until it is known.
+var placeholderBuildID = "0x" + strings.Repeat("00", buildIDSize)
+
+// buildWithDebugSymbols builds the targets with their debugging information
+// into a scratch directory first, and gives every binary a GNU build ID
+// derived from its content. The binary is then linked again with the same
+// build ID, stripped of its symbol table and DWARF debugging information, into
+// the output directory, while the unstripped binary is moved into the debug
+// symbols output under its build ID. Crash dumps of the stripped binaries
+// record the build ID, which is how debuggers find the matching symbols. The
+// second build only links the packages compiled by the first. The unstripped
+// binaries are returned by the path of the binary that they belong to.
+func (p GoBuildProcess) buildWithDebugSymbols(config GoBuildConfiguration, modules []targetModule, names map[string]string, env []string) ([][]string, map[string]string, error) {
+	scratch, err := os.MkdirTemp("", "go-build-symbols")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create debug symbols directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	unstripped := config
+	unstripped.Output = scratch
+	for _, group := range groupTargets(unstripped, modules, names) {
+		flags := linkerFlags(unstrippedFlags(buildFlags(unstripped, group.configuration)), "-B", placeholderBuildID)
+
+		_, err = p.buildGroup(unstripped, group, flags, env)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var commands [][]string
+	symbols := map[string]string{}
+	for _, module := range modules {
+		for _, target := range module.targets {
+			name := names[target]
+			id, err := setBuildID(filepath.Join(scratch, name))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			group := targetGroup{
+				module:        module,
+				targets:       []string{module.relative(config.Workspace, target)},
+				output:        filepath.Join(config.Output, name),
+				configuration: config.TargetConfiguration[target],
+			}
+
+			flags := linkerFlags(buildFlags(config, group.configuration), "-s", "-w", "-B", "0x"+id)
+
+			command, err := p.buildGroup(config, group, flags, env)
+			if err != nil {
+				return nil, nil, err
+			}
+			commands = append(commands, command)
+
+			path := debugSymbolsPath(config.DebugSymbolsOutput, id)
+			err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create debug symbols directory: %w", err)
+			}
+
+			err = fs.Move(filepath.Join(scratch, name), path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to move debug symbols of %s: %w", name, err)
+			}
+
+			symbols[group.output] = path
+
+			p.logs.Action("%s: build ID %s", name, id)
+		}
+	}
+
+	return commands, symbols, nil
+}
+
+// linkerFlags appends the given flags to the value of the -ldflags flag, or
+// adds an -ldflags flag when there is none.
+func linkerFlags(flags []string, extra ...string) []string {
+	flags = slices.Clone(flags)
+	for i := 0; i < len(flags); i++ {
+		name, ok := flagName(flags[i])
+		if !ok || name != "ldflags" {
+			continue
+		}
+
+		index, prefix, ok := flagValue(flags, i)
+		if !ok {
+			break
+		}
+
+		value := strings.TrimPrefix(flags[index], prefix)
+		flags[index] = prefix + strings.TrimSpace(value+" "+strings.Join(extra, " "))
+
+		return flags
+	}
+
+	return append(flags, "-ldflags="+strings.Join(extra, " "))
+}
+
+// setBuildID replaces the placeholder in the GNU build ID note of the binary
+// with the first bytes of the SHA-256 checksum of its content, and returns the
+// build ID in hexadecimal.
+func setBuildID(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read binary: %w", err)
+	}
+
+	file, err := elf.NewFile(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to read binary %s: %w", path, err)
+	}
+
+	// The note holds the sizes of its name and description, its type and the
+	// name "GNU\x00", each four bytes long, followed by the build ID
+	section := file.Section(".note.gnu.build-id")
+	if section == nil || section.Size < 16+buildIDSize || section.Offset+section.Size > uint64(len(content)) ||
+		file.ByteOrder.Uint32(content[section.Offset+4:]) != buildIDSize {
+		return "", fmt.Errorf("failed to find the build ID note of %s", path)
+	}
+
+	start := section.Offset + 16
+	sum := sha256.Sum256(content)
+	copy(content[start:start+buildIDSize], sum[:buildIDSize])
+
+	err = os.WriteFile(path, content, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("failed to write binary: %w", err)
+	}
+
+	return hex.EncodeToString(sum[:buildIDSize]), nil
+}
+
+// debugSymbolsPath returns where the unstripped binary with the given build ID
+// is kept in the debug symbols directory.
+func debugSymbolsPath(dir, id string) string {
+	return filepath.Join(dir, buildIDDirectory, id[:2], id[2:]+".debug")
+}
@@ -15,7 +15,8 @@ type ConfigurationParser interface {
 
 func Detect(parser ConfigurationParser) packit.DetectFunc {
 	return func(context packit.DetectContext) (packit.DetectResult, error) {
-		if _, err := parser.Parse(context.BuildpackInfo.Version, context.WorkingDir); err != nil {
+		configuration, err := parser.Parse(context.BuildpackInfo.Version, context.WorkingDir)
+		if err != nil {
 			return packit.DetectResult{}, packit.Fail.WithMessage("failed to parse build configuration: %w", err)
 		}
 
@@ -42,6 +43,15 @@ func Detect(parser ConfigurationParser) packit.DetectFunc {
 			})
 		}
 
+		if configuration.Debug {
+			requirements = append(requirements, packit.BuildPlanRequirement{
+				Name: "delve",
+				Metadata: map[string]interface{}{
+					"launch": true,
+				},
+			})
+		}
+
 		return packit.DetectResult{
 			Plan: packit.BuildPlan{
 				Requires: requirements,
@@ -92,6 +92,28 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when the build is in debug mode", func() {
+		it.Before(func() {
+			parser.ParseCall.Returns.BuildConfiguration.Debug = true
+		})
+
+		it("requires delve at launch time", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				BuildpackInfo: packit.BuildpackInfo{
+					Version: "some-buildpack-version",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+				Name: "delve",
+				Metadata: map[string]interface{}{
+					"launch": true,
+				},
+			}))
+		})
+	})
+
 	context("failure cases", func() {
 		context("when the configuration parser fails", func() {
 			it.Before(func() {
@@ -7,7 +7,7 @@ import (
 )
 
 type BuildProcess struct {
-	ExecuteCall struct {
+	CompileTestsCall struct {
 		mutex     sync.Mutex
 		CallCount int
 		Receives  struct {
@@ -19,9 +19,32 @@ type BuildProcess struct {
 		}
 		Stub func(gobuild.GoBuildConfiguration) ([]string, error)
 	}
+	ExecuteCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Config gobuild.GoBuildConfiguration
+		}
+		Returns struct {
+			Binaries []string
+			Details  gobuild.BuildDetails
+			Err      error
+		}
+		Stub func(gobuild.GoBuildConfiguration) ([]string, gobuild.BuildDetails, error)
+	}
 }
 
-func (f *BuildProcess) Execute(param1 gobuild.GoBuildConfiguration) ([]string, error) {
+func (f *BuildProcess) CompileTests(param1 gobuild.GoBuildConfiguration) ([]string, error) {
+	f.CompileTestsCall.mutex.Lock()
+	defer f.CompileTestsCall.mutex.Unlock()
+	f.CompileTestsCall.CallCount++
+	f.CompileTestsCall.Receives.Config = param1
+	if f.CompileTestsCall.Stub != nil {
+		return f.CompileTestsCall.Stub(param1)
+	}
+	return f.CompileTestsCall.Returns.Binaries, f.CompileTestsCall.Returns.Err
+}
+func (f *BuildProcess) Execute(param1 gobuild.GoBuildConfiguration) ([]string, gobuild.BuildDetails, error) {
 	f.ExecuteCall.mutex.Lock()
 	defer f.ExecuteCall.mutex.Unlock()
 	f.ExecuteCall.CallCount++
@@ -29,5 +52,5 @@ func (f *BuildProcess) Execute(param1 gobuild.GoBuildConfiguration) ([]string, e
 	if f.ExecuteCall.Stub != nil {
 		return f.ExecuteCall.Stub(param1)
 	}
-	return f.ExecuteCall.Returns.Binaries, f.ExecuteCall.Returns.Err
+	return f.ExecuteCall.Returns.Binaries, f.ExecuteCall.Returns.Details, f.ExecuteCall.Returns.Err
 }
@@ -0,0 +1,32 @@
+package fakes
+
+import (
+	"sync"
+
+	gobuild "github.com/paketo-buildpacks/go-build"
+)
+
+type LicenseCollector struct {
+	CollectCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Config gobuild.LicenseCollectionConfiguration
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(gobuild.LicenseCollectionConfiguration) error
+	}
+}
+
+func (f *LicenseCollector) Collect(param1 gobuild.LicenseCollectionConfiguration) error {
+	f.CollectCall.mutex.Lock()
+	defer f.CollectCall.mutex.Unlock()
+	f.CollectCall.CallCount++
+	f.CollectCall.Receives.Config = param1
+	if f.CollectCall.Stub != nil {
+		return f.CollectCall.Stub(param1)
+	}
+	return f.CollectCall.Returns.Error
+}
@@ -0,0 +1,33 @@
+package fakes
+
+import (
+	"sync"
+
+	gobuild "github.com/paketo-buildpacks/go-build"
+)
+
+type ProfileResolver struct {
+	ResolveCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Config gobuild.ProfileConfiguration
+		}
+		Returns struct {
+			ProfileSet gobuild.ProfileSet
+			Error      error
+		}
+		Stub func(gobuild.ProfileConfiguration) (gobuild.ProfileSet, error)
+	}
+}
+
+func (f *ProfileResolver) Resolve(param1 gobuild.ProfileConfiguration) (gobuild.ProfileSet, error) {
+	f.ResolveCall.mutex.Lock()
+	defer f.ResolveCall.mutex.Unlock()
+	f.ResolveCall.CallCount++
+	f.ResolveCall.Receives.Config = param1
+	if f.ResolveCall.Stub != nil {
+		return f.ResolveCall.Stub(param1)
+	}
+	return f.ResolveCall.Returns.ProfileSet, f.ResolveCall.Returns.Error
+}
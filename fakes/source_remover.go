@@ -9,22 +9,24 @@ type SourceRemover struct {
 		mutex     sync.Mutex
 		CallCount int
 		Receives  struct {
-			Path string
+			Path      string
+			KeepFiles []string
 		}
 		Returns struct {
 			Error error
 		}
-		Stub func(string) error
+		Stub func(string, []string) error
 	}
 }
 
-func (f *SourceRemover) Clear(param1 string) error {
+func (f *SourceRemover) Clear(param1 string, param2 []string) error {
 	f.ClearCall.mutex.Lock()
 	defer f.ClearCall.mutex.Unlock()
 	f.ClearCall.CallCount++
 	f.ClearCall.Receives.Path = param1
+	f.ClearCall.Receives.KeepFiles = param2
 	if f.ClearCall.Stub != nil {
-		return f.ClearCall.Stub(param1)
+		return f.ClearCall.Stub(param1, param2)
 	}
 	return f.ClearCall.Returns.Error
 }
@@ -23,12 +23,13 @@ type TargetManager struct {
 		CallCount int
 		Receives  struct {
 			WorkingDir string
+			Depth      int
 		}
 		Returns struct {
 			StringSlice []string
 			Error       error
 		}
-		Stub func(string) ([]string, error)
+		Stub func(string, int) ([]string, error)
 	}
 }
 
@@ -43,13 +44,14 @@ func (f *TargetManager) CleanAndValidate(param1 []string, param2 string) ([]stri
 	}
 	return f.CleanAndValidateCall.Returns.StringSlice, f.CleanAndValidateCall.Returns.Error
 }
-func (f *TargetManager) GenerateDefaults(param1 string) ([]string, error) {
+func (f *TargetManager) GenerateDefaults(param1 string, param2 int) ([]string, error) {
 	f.GenerateDefaultsCall.mutex.Lock()
 	defer f.GenerateDefaultsCall.mutex.Unlock()
 	f.GenerateDefaultsCall.CallCount++
 	f.GenerateDefaultsCall.Receives.WorkingDir = param1
+	f.GenerateDefaultsCall.Receives.Depth = param2
 	if f.GenerateDefaultsCall.Stub != nil {
-		return f.GenerateDefaultsCall.Stub(param1)
+		return f.GenerateDefaultsCall.Stub(param1, param2)
 	}
 	return f.GenerateDefaultsCall.Returns.StringSlice, f.GenerateDefaultsCall.Returns.Error
 }
@@ -0,0 +1,27 @@
+package fakes
+
+import (
+	"sync"
+)
+
+type Toolchain struct {
+	VersionCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Returns   struct {
+			String string
+			Error  error
+		}
+		Stub func() (string, error)
+	}
+}
+
+func (f *Toolchain) Version() (string, error) {
+	f.VersionCall.mutex.Lock()
+	defer f.VersionCall.mutex.Unlock()
+	f.VersionCall.CallCount++
+	if f.VersionCall.Stub != nil {
+		return f.VersionCall.Stub()
+	}
+	return f.VersionCall.Returns.String, f.VersionCall.Returns.Error
+}
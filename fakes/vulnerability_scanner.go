@@ -0,0 +1,54 @@
+package fakes
+
+import (
+	"sync"
+
+	gobuild "github.com/paketo-buildpacks/go-build"
+)
+
+type VulnerabilityScanner struct {
+	DatabaseVersionCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Config gobuild.VulnerabilityScanConfiguration
+		}
+		Returns struct {
+			String string
+			Error  error
+		}
+		Stub func(gobuild.VulnerabilityScanConfiguration) (string, error)
+	}
+	ScanCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Config gobuild.VulnerabilityScanConfiguration
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(gobuild.VulnerabilityScanConfiguration) error
+	}
+}
+
+func (f *VulnerabilityScanner) DatabaseVersion(param1 gobuild.VulnerabilityScanConfiguration) (string, error) {
+	f.DatabaseVersionCall.mutex.Lock()
+	defer f.DatabaseVersionCall.mutex.Unlock()
+	f.DatabaseVersionCall.CallCount++
+	f.DatabaseVersionCall.Receives.Config = param1
+	if f.DatabaseVersionCall.Stub != nil {
+		return f.DatabaseVersionCall.Stub(param1)
+	}
+	return f.DatabaseVersionCall.Returns.String, f.DatabaseVersionCall.Returns.Error
+}
+func (f *VulnerabilityScanner) Scan(param1 gobuild.VulnerabilityScanConfiguration) error {
+	f.ScanCall.mutex.Lock()
+	defer f.ScanCall.mutex.Unlock()
+	f.ScanCall.CallCount++
+	f.ScanCall.Receives.Config = param1
+	if f.ScanCall.Stub != nil {
+		return f.ScanCall.Stub(param1)
+	}
+	return f.ScanCall.Returns.Error
+}
@@ -5,14 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
 
+	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
@@ -24,67 +30,155 @@ type Executable interface {
 
 type GoBuildConfiguration struct {
 	Workspace           string
+	PlatformPath        string
 	Output              string
 	GoPath              string
 	GoCache             string
+	GoModCache          string
 	Targets             []string
+	TargetConfiguration map[string]TargetConfiguration
 	Flags               []string
 	DisableCGO          bool
 	WorkspaceUseModules []string
+	Offline             bool
+	Reproducible        bool
+	VerifyReproducible  bool
+	Debug               bool
+	DebugSymbolsOutput  string
+	PGO                 string
+	GeneratePatterns    []string
+	VetPatterns         []string
+	VetFlags            []string
+	TestPatterns        []string
+	TestFlags           []string
+	TestBinaryPatterns  []string
+	TestBinaryOutput    string
+}
+
+// BuildDetails describes how the binaries were built.
+type BuildDetails struct {
+	// Commands are the 'go build' commands as they were logged.
+	Commands [][]string
+
+	// Env holds the variables of the environment passed to 'go build' that
+	// affect the binaries, as returned by outputEnvironment.
+	Env []string
+
+	// DebugSymbols holds the unstripped copies of the binaries whose debug
+	// symbols were split from them, by the path of the binary.
+	DebugSymbols map[string]string
 }
 
 type GoBuildProcess struct {
 	executable Executable
 	logs       scribe.Emitter
 	clock      chronos.Clock
+	bindings   BindingResolver
 }
 
-func NewGoBuildProcess(executable Executable, logs scribe.Emitter, clock chronos.Clock) GoBuildProcess {
+func NewGoBuildProcess(executable Executable, logs scribe.Emitter, clock chronos.Clock, bindings BindingResolver) GoBuildProcess {
 	return GoBuildProcess{
 		executable: executable,
 		logs:       logs,
 		clock:      clock,
+		bindings:   bindings,
 	}
 }
 
-func (p GoBuildProcess) Execute(config GoBuildConfiguration) ([]string, error) {
+// Execute builds the targets and returns the paths of the binaries along with
+// the details of the 'go build' commands that produced them.
+func (p GoBuildProcess) Execute(config GoBuildConfiguration) ([]string, BuildDetails, error) {
 	p.logs.Process("Executing build process")
 
 	err := os.MkdirAll(config.Output, os.ModePerm)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create targets output directory: %w", err)
+		return nil, BuildDetails{}, fmt.Errorf("failed to create targets output directory: %w", err)
 	}
 
-	if !containsFlag(config.Flags, "-buildmode") {
-		config.Flags = append(config.Flags, "-buildmode", "pie")
+	env, cleanup, err := p.environment(config)
+	if err != nil {
+		return nil, BuildDetails{}, err
 	}
+	defer cleanup()
 
-	if !containsFlag(config.Flags, "-trimpath") {
-		config.Flags = append(config.Flags, "-trimpath")
-	}
+	if len(config.WorkspaceUseModules) > 0 {
+		// An existing go.work file already describes the workspace and would
+		// cause 'go work init' to fail
+		hasGoWork, err := fs.Exists(filepath.Join(config.Workspace, "go.work"))
+		if err != nil {
+			return nil, BuildDetails{}, fmt.Errorf("failed to check for go.work: %w", err)
+		}
 
-	args := append([]string{"build", "-o", config.Output}, config.Flags...)
-	args = append(args, config.Targets...)
+		if hasGoWork {
+			p.logs.Subprocess("Using the existing go.work file")
+		} else {
+			// go work init
+			workInitArgs := []string{"work", "init"}
+			p.logs.Subprocess("Running '%s'", strings.Join(append([]string{"go"}, workInitArgs...), " "))
 
-	env := append(os.Environ(), fmt.Sprintf("GOCACHE=%s", config.GoCache))
-	if config.GoPath != "" {
-		env = append(env, fmt.Sprintf("GOPATH=%s", config.GoPath))
+			duration, err := p.clock.Measure(func() error {
+				return p.executable.Execute(pexec.Execution{
+					Args:   workInitArgs,
+					Dir:    config.Workspace,
+					Env:    env,
+					Stdout: p.logs.ActionWriter,
+					Stderr: p.logs.ActionWriter,
+				})
+			})
+			if err != nil {
+				p.logs.Action("Failed after %s", duration.Round(time.Millisecond))
+				return nil, BuildDetails{}, fmt.Errorf("failed to execute '%s': %w", workInitArgs, err)
+			}
+
+			// go work use <modules...>
+			workUseArgs := append([]string{"work", "use"}, config.WorkspaceUseModules...)
+			p.logs.Subprocess("Running '%s'", strings.Join(append([]string{"go"}, workUseArgs...), " "))
+
+			duration, err = p.clock.Measure(func() error {
+				return p.executable.Execute(pexec.Execution{
+					Args:   workUseArgs,
+					Dir:    config.Workspace,
+					Env:    env,
+					Stdout: p.logs.ActionWriter,
+					Stderr: p.logs.ActionWriter,
+				})
+			})
+			if err != nil {
+				p.logs.Action("Failed after %s", duration.Round(time.Millisecond))
+				return nil, BuildDetails{}, fmt.Errorf("failed to execute '%s': %w", workUseArgs, err)
+			}
+		}
 	}
-	env = append(env, "GO111MODULE=auto")
 
-	if config.DisableCGO {
-		env = append(env, "CGO_ENABLED=0")
+	modules, err := findTargetModules(config)
+	if err != nil {
+		return nil, BuildDetails{}, err
 	}
 
-	if len(config.WorkspaceUseModules) > 0 {
-		// go work init
-		workInitArgs := []string{"work", "init"}
-		p.logs.Subprocess("Running '%s'", strings.Join(append([]string{"go"}, workInitArgs...), " "))
+	// The binary names are resolved before any of the slower steps so that
+	// targets that produce the same binary are reported right away
+	names, err := p.resolveBinaryNames(config, modules, env)
+	if err != nil {
+		return nil, BuildDetails{}, err
+	}
+
+	for _, module := range modules {
+		shouldDownload, err := shouldDownloadModules(config, module.dir)
+		if err != nil {
+			return nil, BuildDetails{}, err
+		}
+
+		if !shouldDownload {
+			continue
+		}
+
+		modDownloadArgs := []string{"mod", "download"}
+		p.logs.Subprocess("Running '%s'%s", strings.Join(append([]string{"go"}, modDownloadArgs...), " "), module.location(config.Workspace))
 
 		duration, err := p.clock.Measure(func() error {
 			return p.executable.Execute(pexec.Execution{
-				Args:   workInitArgs,
-				Dir:    config.Workspace,
+				Args:   modDownloadArgs,
+				Dir:    module.dir,
 				Env:    env,
 				Stdout: p.logs.ActionWriter,
 				Stderr: p.logs.ActionWriter,
@@ -92,17 +186,24 @@ func (p GoBuildProcess) Execute(config GoBuildConfiguration) ([]string, error) {
 		})
 		if err != nil {
 			p.logs.Action("Failed after %s", duration.Round(time.Millisecond))
-			return nil, fmt.Errorf("failed to execute '%s': %w", workInitArgs, err)
+			if config.Offline {
+				return nil, BuildDetails{}, fmt.Errorf("failed to execute 'go mod download': the build is offline and the modules are missing from the module mirror or cache: %w", err)
+			}
+
+			return nil, BuildDetails{}, fmt.Errorf("failed to execute 'go mod download': %w", err)
 		}
 
-		// go work use <modules...>
-		workUseArgs := append([]string{"work", "use"}, config.WorkspaceUseModules...)
-		p.logs.Subprocess("Running '%s'", strings.Join(append([]string{"go"}, workUseArgs...), " "))
+		p.logs.Action("Completed in %s", duration.Round(time.Millisecond))
+	}
+
+	for _, packages := range modulePatterns(config.Workspace, modules, config.GeneratePatterns) {
+		generateArgs := append([]string{"generate"}, packages.patterns...)
+		p.logs.Subprocess("Running '%s'%s", strings.Join(append([]string{"go"}, generateArgs...), " "), packages.module.location(config.Workspace))
 
-		duration, err = p.clock.Measure(func() error {
+		duration, err := p.clock.Measure(func() error {
 			return p.executable.Execute(pexec.Execution{
-				Args:   workUseArgs,
-				Dir:    config.Workspace,
+				Args:   generateArgs,
+				Dir:    packages.module.dir,
 				Env:    env,
 				Stdout: p.logs.ActionWriter,
 				Stderr: p.logs.ActionWriter,
@@ -110,15 +211,172 @@ func (p GoBuildProcess) Execute(config GoBuildConfiguration) ([]string, error) {
 		})
 		if err != nil {
 			p.logs.Action("Failed after %s", duration.Round(time.Millisecond))
-			return nil, fmt.Errorf("failed to execute '%s': %w", workUseArgs, err)
+			return nil, BuildDetails{}, fmt.Errorf("failed to execute 'go generate': %w", err)
+		}
+
+		p.logs.Action("Completed in %s", duration.Round(time.Millisecond))
+	}
+
+	for _, packages := range modulePatterns(config.Workspace, modules, config.VetPatterns) {
+		err = p.vet(config, packages, env)
+		if err != nil {
+			return nil, BuildDetails{}, err
 		}
 	}
 
-	printedArgs := []string{"go"}
-	for _, arg := range args {
-		printedArgs = append(printedArgs, formatArg(arg))
+	for _, packages := range modulePatterns(config.Workspace, modules, config.TestPatterns) {
+		err = p.test(config, packages, env)
+		if err != nil {
+			return nil, BuildDetails{}, err
+		}
+	}
+
+	commands, symbols, err := p.build(config, modules, names, env)
+	if err != nil {
+		return nil, BuildDetails{}, err
+	}
+
+	if config.VerifyReproducible {
+		err = p.verifyReproducible(config, modules, names, env)
+		if err != nil {
+			return nil, BuildDetails{}, err
+		}
+	}
+	p.logs.Break()
+
+	var paths []string
+	for _, target := range config.Targets {
+		paths = append(paths, filepath.Join(config.Output, names[target]))
+	}
+
+	if len(paths) == 0 {
+		return nil, BuildDetails{}, errors.New("failed to determine go executable start command")
+	}
+
+	if config.Reproducible {
+		err = setSourceDateEpoch(paths, sourceDateEpoch())
+		if err != nil {
+			return nil, BuildDetails{}, err
+		}
+	}
+
+	return paths, BuildDetails{
+		Commands:     commands,
+		Env:          outputEnvironment(env),
+		DebugSymbols: symbols,
+	}, nil
+}
+
+// build runs 'go build' for every group of targets, writing the binaries to
+// the output directory of the given configuration. The commands are returned
+// as they were logged. Targets whose debug symbols are split from the
+// binaries are built by buildWithDebugSymbols instead, which also returns the
+// unstripped binaries.
+func (p GoBuildProcess) build(config GoBuildConfiguration, modules []targetModule, names map[string]string, env []string) ([][]string, map[string]string, error) {
+	if config.DebugSymbolsOutput != "" {
+		return p.buildWithDebugSymbols(config, modules, names, env)
+	}
+
+	var commands [][]string
+	for _, group := range groupTargets(config, modules, names) {
+		command, err := p.buildGroup(config, group, buildFlags(config, group.configuration), env)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		commands = append(commands, command)
+	}
+
+	return commands, nil, nil
+}
+
+// buildGroup runs 'go build' with the given flags for a group of targets and
+// returns the command as it was logged.
+func (p GoBuildProcess) buildGroup(config GoBuildConfiguration, group targetGroup, flags []string, env []string) ([]string, error) {
+	args := append([]string{"build", "-o", group.output}, flags...)
+	args = append(args, group.targets...)
+
+	groupEnv := append(append([]string{}, env...), group.configuration.Env...)
+
+	p.logs.Subprocess("Running '%s'%s", formatCommand(args), group.module.location(config.Workspace))
+
+	duration, err := p.clock.Measure(func() error {
+		return p.executable.Execute(pexec.Execution{
+			Args:   args,
+			Dir:    group.module.dir,
+			Env:    groupEnv,
+			Stdout: p.logs.ActionWriter,
+			Stderr: p.logs.ActionWriter,
+		})
+	})
+	if err != nil {
+		p.logs.Action("Failed after %s", duration.Round(time.Millisecond))
+		return nil, fmt.Errorf("failed to execute 'go build': %w", err)
+	}
+
+	p.logs.Action("Completed in %s", duration.Round(time.Millisecond))
+
+	return append([]string{"go"}, args...), nil
+}
+
+// buildFlags returns the flags that 'go build' runs with for targets with the
+// given configuration.
+func buildFlags(config GoBuildConfiguration, configuration TargetConfiguration) []string {
+	flags := mergeFlags(config.Flags, configuration.Flags)
+
+	if !containsFlag(flags, "-buildmode") {
+		flags = append(flags, "-buildmode", "pie")
+	}
+
+	if config.PGO != "" && !containsFlag(flags, "-pgo") {
+		flags = append(flags, "-pgo="+config.PGO)
+	}
+
+	// Debuggers look up the source by the paths in the binaries, which are
+	// kept as they are in debug mode
+	if !containsFlag(flags, "-trimpath") && !config.Debug {
+		flags = append(flags, "-trimpath")
 	}
-	p.logs.Subprocess("Running '%s'", strings.Join(printedArgs, " "))
+
+	if config.Reproducible {
+		flags = reproducibleFlags(flags)
+	}
+
+	if config.Debug {
+		flags = debugFlags(flags)
+	}
+
+	return flags
+}
+
+// CompileTests compiles the test binaries of the configured packages with
+// 'go test -c' so that they can be run from the built image. It is expected
+// to run after Execute, which prepares the workspace and downloads the
+// modules.
+func (p GoBuildProcess) CompileTests(config GoBuildConfiguration) ([]string, error) {
+	p.logs.Process("Compiling test binaries")
+
+	err := os.MkdirAll(config.TestBinaryOutput, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create test binaries output directory: %w", err)
+	}
+
+	// The test binaries are not targets themselves, so only the settings that
+	// apply to every target are used
+	flags := buildFlags(config, TargetConfiguration{})
+
+	// A trailing separator makes 'go test' write a <package>.test binary for
+	// every package that contains tests into the directory.
+	args := append([]string{"test", "-c", "-o", config.TestBinaryOutput + string(filepath.Separator)}, flags...)
+	args = append(args, config.TestBinaryPatterns...)
+
+	env, cleanup, err := p.environment(config)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	p.logs.Subprocess("Running '%s'", formatCommand(args))
 
 	duration, err := p.clock.Measure(func() error {
 		return p.executable.Execute(pexec.Execution{
@@ -131,43 +389,658 @@ func (p GoBuildProcess) Execute(config GoBuildConfiguration) ([]string, error) {
 	})
 	if err != nil {
 		p.logs.Action("Failed after %s", duration.Round(time.Millisecond))
-		return nil, fmt.Errorf("failed to execute 'go build': %w", err)
+		return nil, fmt.Errorf("failed to execute 'go test -c': %w", err)
 	}
 
 	p.logs.Action("Completed in %s", duration.Round(time.Millisecond))
 	p.logs.Break()
 
-	var paths []string
-	for _, target := range config.Targets {
-		buffer := bytes.NewBuffer(nil)
-		err := p.executable.Execute(pexec.Execution{
-			Args:   []string{"list", "--json", target},
-			Dir:    config.Workspace,
+	paths, err := filepath.Glob(filepath.Join(config.TestBinaryOutput, "*.test"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find test binaries: %w", err)
+	}
+
+	if len(paths) == 0 {
+		return nil, packit.Fail.WithMessage("failed to compile test binaries: none of the packages matching %s contain tests", strings.Join(config.TestBinaryPatterns, ", "))
+	}
+
+	if config.Reproducible {
+		err = setSourceDateEpoch(paths, sourceDateEpoch())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return paths, nil
+}
+
+// environment returns the environment that every go command of the build
+// runs with, along with a function that removes the private module
+// credentials once they are no longer needed.
+func (p GoBuildProcess) environment(config GoBuildConfiguration) ([]string, func() error, error) {
+	credentials, err := setupModuleCredentials(p.bindings, config.PlatformPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, binding := range credentials.bindings {
+		p.logs.Subprocess("Using %s binding %q for private modules", binding.Type, binding.Name)
+	}
+
+	var offline offlineMode
+	if config.Offline {
+		offline, err = resolveOfflineMode(p.bindings, config.Workspace, config.PlatformPath)
+		if err != nil {
+			_ = credentials.Remove()
+			return nil, nil, err
+		}
+
+		for _, description := range offline.descriptions() {
+			p.logs.Subprocess(description)
+		}
+	}
+
+	env := append(buildEnvironment(config, offline.goFlags()...), credentials.env...)
+	if config.Offline {
+		// The offline settings come last so that they take precedence over
+		// the proxy settings of the builder and the bindings
+		env = append(env, offline.env()...)
+	}
+
+	return env, credentials.Remove, nil
+}
+
+// buildEnvironment returns the environment of the go commands before any
+// bindings are applied.
+func buildEnvironment(config GoBuildConfiguration, goFlags ...string) []string {
+	env := os.Environ()
+	if config.Reproducible {
+		env = append(scrubEnvironment(env), "TZ=UTC", "LC_ALL=C", fmt.Sprintf("SOURCE_DATE_EPOCH=%s", sourceDateEpoch()))
+	}
+
+	env = append(env, fmt.Sprintf("GOCACHE=%s", config.GoCache))
+	if config.GoPath != "" {
+		env = append(env, fmt.Sprintf("GOPATH=%s", config.GoPath))
+	}
+	env = append(env, "GO111MODULE=auto")
+
+	if config.GoModCache != "" {
+		env = append(env, fmt.Sprintf("GOMODCACHE=%s", config.GoModCache))
+
+		// The module cache is read-only by default which would prevent the cached
+		// layer from being removed once it has been invalidated.
+		goFlags = append([]string{"-modcacherw"}, goFlags...)
+	}
+
+	if len(goFlags) > 0 {
+		env = append(env, fmt.Sprintf("GOFLAGS=%s", strings.TrimSpace(fmt.Sprintf("%s %s", os.Getenv("GOFLAGS"), strings.Join(goFlags, " ")))))
+	}
+
+	if config.DisableCGO {
+		env = append(env, "CGO_ENABLED=0")
+	}
+
+	return env
+}
+
+// vet runs 'go vet' on the packages of a module and fails the build when it
+// reports problems.
+func (p GoBuildProcess) vet(config GoBuildConfiguration, packages modulePackages, env []string) error {
+	args := append(append([]string{"vet"}, config.VetFlags...), packages.patterns...)
+	p.logs.Subprocess("Running '%s'%s", formatCommand(args), packages.module.location(config.Workspace))
+
+	stderr := bytes.NewBuffer(nil)
+	duration, err := p.clock.Measure(func() error {
+		return p.executable.Execute(pexec.Execution{
+			Args:   args,
+			Dir:    packages.module.dir,
+			Env:    env,
+			Stdout: p.logs.ActionWriter,
+			Stderr: io.MultiWriter(p.logs.ActionWriter, stderr),
+		})
+	})
+	if err != nil {
+		p.logs.Action("Failed after %s", duration.Round(time.Millisecond))
+		return failedPackagesError("go vet", vetPackages(config.Workspace, packages.module.dir, stderr.String()), err)
+	}
+
+	p.logs.Action("Completed in %s", duration.Round(time.Millisecond))
+	return nil
+}
+
+// vetDiagnosticPattern matches the "file:line:col: message" lines that 'go
+// vet' reports its diagnostics with.
+var vetDiagnosticPattern = regexp.MustCompile(`^(\S+\.go):\d+(?::\d+)?: `)
+
+// vetPackages returns the directories, relative to the workspace, of the
+// packages that the diagnostics of 'go vet' refer to. The file paths in the
+// diagnostics are relative to the directory that 'go vet' ran in.
+func vetPackages(workspace, dir, output string) []string {
+	var packages []string
+	for _, line := range strings.Split(output, "\n") {
+		match := vetDiagnosticPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		path := match[1]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		rel, err := filepath.Rel(workspace, filepath.Dir(path))
+		if err != nil {
+			continue
+		}
+
+		if pkg := cleanTarget(rel); !slices.Contains(packages, pkg) {
+			packages = append(packages, pkg)
+		}
+	}
+
+	return packages
+}
+
+// test runs 'go test' on the packages of a module and fails the build when any
+// of them fail. The JSON output of 'go test' is used to determine which
+// packages failed while the regular test output is still logged.
+func (p GoBuildProcess) test(config GoBuildConfiguration, packages modulePackages, env []string) error {
+	args := []string{"test"}
+	if !containsFlag(config.TestFlags, "-json") {
+		args = append(args, "-json")
+	}
+	args = append(append(args, config.TestFlags...), packages.patterns...)
+	p.logs.Subprocess("Running '%s'%s", formatCommand(args), packages.module.location(config.Workspace))
+
+	events := &testEventWriter{writer: p.logs.ActionWriter}
+	duration, err := p.clock.Measure(func() error {
+		return p.executable.Execute(pexec.Execution{
+			Args:   args,
+			Dir:    packages.module.dir,
 			Env:    env,
-			Stdout: buffer,
-			Stderr: buffer,
+			Stdout: events,
+			Stderr: p.logs.ActionWriter,
 		})
+	})
+	events.Flush()
+	if err != nil {
+		p.logs.Action("Failed after %s", duration.Round(time.Millisecond))
+		return failedPackagesError("go test", events.failed, err)
+	}
+
+	p.logs.Action("Completed in %s", duration.Round(time.Millisecond))
+	return nil
+}
+
+// failedPackagesError returns a build failure listing the packages that did
+// not pass the given command. When the failing packages are unknown the
+// command itself could not be run and the error is returned as-is.
+func failedPackagesError(command string, packages []string, err error) error {
+	if len(packages) == 0 {
+		return fmt.Errorf("failed to execute '%s': %w", command, err)
+	}
+
+	return packit.Fail.WithMessage("'%s' failed for the following packages:\n  %s", command, strings.Join(packages, "\n  "))
+}
+
+// testEventWriter decodes the event stream written by 'go test -json'. The
+// output of each event is passed on to the underlying writer and the packages
+// that failed are recorded.
+type testEventWriter struct {
+	writer io.Writer
+	buffer []byte
+	failed []string
+}
+
+func (w *testEventWriter) Write(b []byte) (int, error) {
+	w.buffer = append(w.buffer, b...)
+	for {
+		i := bytes.IndexByte(w.buffer, '\n')
+		if i < 0 {
+			break
+		}
+
+		err := w.handle(w.buffer[:i+1])
+		w.buffer = w.buffer[i+1:]
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Flush handles any remaining output that was not terminated by a newline.
+func (w *testEventWriter) Flush() {
+	if len(w.buffer) > 0 {
+		_ = w.handle(w.buffer)
+		w.buffer = nil
+	}
+}
+
+func (w *testEventWriter) handle(line []byte) error {
+	var event struct {
+		Action  string
+		Package string
+		Test    string
+		Output  string
+	}
+
+	err := json.Unmarshal(line, &event)
+	if err != nil {
+		// Lines that are not events, such as those printed when -json was
+		// overridden, are passed through untouched
+		_, err = w.writer.Write(line)
+		return err
+	}
+
+	if event.Action == "fail" && event.Test == "" && event.Package != "" && !slices.Contains(w.failed, event.Package) {
+		w.failed = append(w.failed, event.Package)
+	}
+
+	if event.Output == "" {
+		return nil
+	}
+
+	_, err = io.WriteString(w.writer, event.Output)
+	return err
+}
+
+// resolveBinaryNames determines the name of the binary that each target will
+// produce and makes sure that no two targets produce the same binary. Targets
+// without an explicit name are named after the last element of their import
+// path, skipping any major version suffix. The packages are only located, not
+// loaded, so their dependencies do not need to be downloaded or generated yet.
+func (p GoBuildProcess) resolveBinaryNames(config GoBuildConfiguration, modules []targetModule, env []string) (map[string]string, error) {
+	names := map[string]string{}
+	owners := map[string]string{}
+	for _, module := range modules {
+		for _, target := range module.targets {
+			name := config.TargetConfiguration[target].Name
+			if name == "" {
+				// The go command reports progress, such as the modules that it
+				// downloads, on stderr, which is kept apart from the JSON output
+				stdout := bytes.NewBuffer(nil)
+				stderr := bytes.NewBuffer(nil)
+				err := p.executable.Execute(pexec.Execution{
+					Args:   []string{"list", "-find", "--json", module.relative(config.Workspace, target)},
+					Dir:    module.dir,
+					Env:    env,
+					Stdout: stdout,
+					Stderr: stderr,
+				})
+				if err != nil {
+					p.logs.Detail(stdout.String() + stderr.String())
+					return nil, fmt.Errorf("failed to execute 'go list': %w", err)
+				}
+
+				var list struct {
+					ImportPath string `json:"ImportPath"`
+				}
+				err = json.Unmarshal(stdout.Bytes(), &list)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse 'go list' output: %w", err)
+				}
+
+				name = binaryName(list.ImportPath)
+			}
+
+			if owner, ok := owners[name]; ok {
+				return nil, fmt.Errorf("failed to determine binary names: targets %q and %q both produce a binary named %q, use name=path in BP_GO_TARGETS to rename one of them", owner, target, name)
+			}
+
+			owners[name] = target
+			names[target] = name
+		}
+	}
+
+	return names, nil
+}
+
+// binaryName mirrors the naming used by 'go install': the last element of the
+// import path, unless that element is a major version suffix (e.g.
+// example.com/app/v2) in which case the element before it is used.
+func binaryName(importPath string) string {
+	dir, name := path.Split(importPath)
+	if dir != "" && isMajorVersionSuffix(name) {
+		_, name = path.Split(path.Dir(importPath))
+	}
+
+	return name
+}
+
+func isMajorVersionSuffix(element string) bool {
+	if len(element) < 2 || element[0] != 'v' || element[1] == '0' || (element[1] == '1' && len(element) == 2) {
+		return false
+	}
+
+	for _, r := range element[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+type targetGroup struct {
+	module        targetModule
+	targets       []string
+	output        string
+	configuration TargetConfiguration
+}
+
+// groupTargets collects the targets of each module that share the same build
+// settings so that they can be compiled together in a single invocation of
+// 'go build'. Targets whose binary name differs from the name 'go build'
+// would choose are compiled on their own so that the output file can be named
+// explicitly. Within a module, the groups are ordered by the first appearance
+// of their targets.
+func groupTargets(config GoBuildConfiguration, modules []targetModule, names map[string]string) []targetGroup {
+	var groups []targetGroup
+	for _, module := range modules {
+		indices := map[string]int{}
+		for _, target := range module.targets {
+			configuration := config.TargetConfiguration[target]
+			if configuration.Name != "" {
+				groups = append(groups, targetGroup{
+					module:        module,
+					targets:       []string{module.relative(config.Workspace, target)},
+					output:        filepath.Join(config.Output, names[target]),
+					configuration: configuration,
+				})
+				continue
+			}
+
+			key := strings.Join(configuration.Flags, "\x00") + "\x01" + strings.Join(configuration.Env, "\x00")
+
+			index, ok := indices[key]
+			if !ok {
+				index = len(groups)
+				indices[key] = index
+				groups = append(groups, targetGroup{module: module, output: config.Output, configuration: configuration})
+			}
+
+			groups[index].targets = append(groups[index].targets, module.relative(config.Workspace, target))
+		}
+	}
+
+	return groups
+}
+
+// targetModule is a module root within the workspace along with the targets
+// that are built from it.
+type targetModule struct {
+	dir     string
+	targets []string
+}
+
+// relative returns the path of the target relative to the module root in the
+// form expected by the go command.
+func (m targetModule) relative(workspace, target string) string {
+	rel, err := filepath.Rel(m.dir, filepath.Join(workspace, target))
+	if err != nil {
+		return "."
+	}
+
+	return cleanTarget(rel)
+}
+
+// modulePackages are the package patterns that a go command is run with in
+// the directory of a module.
+type modulePackages struct {
+	module   targetModule
+	patterns []string
+}
+
+// modulePatterns distributes package patterns, which are relative to the
+// workspace, over the workspace and the nested modules that the targets are
+// built from, as the go command only matches the packages of the module that
+// it runs in. A pattern that ends in /... also covers every nested module
+// below it. Patterns that are not relative paths, such as import paths, are
+// left to the workspace.
+func modulePatterns(workspace string, modules []targetModule, patterns []string) []modulePackages {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	all := []modulePackages{{module: targetModule{dir: workspace}}}
+	for _, module := range modules {
+		if module.dir != workspace {
+			all = append(all, modulePackages{module: module})
+		}
+	}
+
+	// The innermost module that contains a directory owns its packages
+	owner := func(dir string) int {
+		index := 0
+		for i, packages := range all {
+			if isWithin(packages.module.dir, dir) && isWithin(all[index].module.dir, packages.module.dir) {
+				index = i
+			}
+		}
+
+		return index
+	}
+
+	for _, pattern := range patterns {
+		if pattern != "." && pattern != ".." && !strings.HasPrefix(pattern, "./") && !strings.HasPrefix(pattern, "../") {
+			all[0].patterns = append(all[0].patterns, pattern)
+			continue
+		}
+
+		base, recursive := strings.CutSuffix(pattern, "/...")
+		dir := filepath.Join(workspace, base)
+
+		index := owner(dir)
+		rel, err := filepath.Rel(all[index].module.dir, dir)
 		if err != nil {
-			p.logs.Detail(buffer.String())
-			return nil, fmt.Errorf("failed to execute 'go list': %w", err)
+			all[0].patterns = append(all[0].patterns, pattern)
+			continue
 		}
 
-		var list struct {
-			ImportPath string `json:"ImportPath"`
+		if recursive {
+			all[index].patterns = append(all[index].patterns, cleanTarget(rel)+"/...")
+			for i := range all {
+				if i != index && isWithin(dir, all[i].module.dir) {
+					all[i].patterns = append(all[i].patterns, "./...")
+				}
+			}
+			continue
 		}
-		err = json.Unmarshal(buffer.Bytes(), &list)
+
+		all[index].patterns = append(all[index].patterns, cleanTarget(rel))
+	}
+
+	var result []modulePackages
+	for _, packages := range all {
+		if len(packages.patterns) > 0 {
+			result = append(result, packages)
+		}
+	}
+
+	return result
+}
+
+// isWithin reports whether the path is the directory or is inside of it.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// location describes where a command is run when that is somewhere other than
+// the workspace.
+func (m targetModule) location(workspace string) string {
+	if m.dir == workspace {
+		return ""
+	}
+
+	rel, err := filepath.Rel(workspace, m.dir)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf(" in %s", cleanTarget(rel))
+}
+
+// findTargetModules groups the targets by the nearest directory containing a
+// go.mod file so that targets from nested modules can be built from their own
+// module root. A go.work file ties the modules together already, in which case
+// every target is built from the workspace.
+func findTargetModules(config GoBuildConfiguration) ([]targetModule, error) {
+	hasGoWork, err := fs.Exists(filepath.Join(config.Workspace, "go.work"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for go.work: %w", err)
+	}
+
+	if hasGoWork || len(config.WorkspaceUseModules) > 0 {
+		return []targetModule{{dir: config.Workspace, targets: config.Targets}}, nil
+	}
+
+	var modules []targetModule
+	indices := map[string]int{}
+	for _, target := range config.Targets {
+		dir, err := findModuleRoot(config.Workspace, filepath.Join(config.Workspace, target))
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse 'go list' output: %w", err)
+			return nil, err
 		}
 
-		paths = append(paths, filepath.Join(config.Output, filepath.Base(list.ImportPath)))
+		index, ok := indices[dir]
+		if !ok {
+			index = len(modules)
+			indices[dir] = index
+			modules = append(modules, targetModule{dir: dir})
+		}
+
+		modules[index].targets = append(modules[index].targets, target)
 	}
 
-	if len(paths) == 0 {
-		return nil, errors.New("failed to determine go executable start command")
+	return modules, nil
+}
+
+// findModuleRoot returns the nearest directory between dir and the workspace
+// that contains a go.mod file, or the workspace itself when there is none.
+func findModuleRoot(workspace, dir string) (string, error) {
+	for dir != workspace && strings.HasPrefix(dir, workspace+string(filepath.Separator)) {
+		hasGoMod, err := fs.Exists(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return "", fmt.Errorf("failed to check for go.mod: %w", err)
+		}
+
+		if hasGoMod {
+			return dir, nil
+		}
+
+		dir = filepath.Dir(dir)
 	}
 
-	return paths, nil
+	return workspace, nil
+}
+
+// buildValueFlags are the 'go build' flags that may be given their value as
+// a separate argument (e.g. "-tags netgo" rather than "-tags=netgo").
+var buildValueFlags = map[string]bool{
+	"asmflags":      true,
+	"buildmode":     true,
+	"compiler":      true,
+	"coverpkg":      true,
+	"covermode":     true,
+	"gccgoflags":    true,
+	"gcflags":       true,
+	"installsuffix": true,
+	"ldflags":       true,
+	"mod":           true,
+	"modfile":       true,
+	"overlay":       true,
+	"pgo":           true,
+	"pkgdir":        true,
+	"tags":          true,
+	"toolexec":      true,
+}
+
+// mergeFlags returns the base flags with any flag that is also present in the
+// overrides replaced by the override value.
+func mergeFlags(base, overrides []string) []string {
+	if len(overrides) == 0 {
+		return append([]string{}, base...)
+	}
+
+	overridden := map[string]bool{}
+	for _, flag := range overrides {
+		if name, ok := flagName(flag); ok {
+			overridden[name] = true
+		}
+	}
+
+	var flags []string
+	for i := 0; i < len(base); i++ {
+		name, ok := flagName(base[i])
+		if ok && overridden[name] {
+			if !strings.Contains(base[i], "=") && buildValueFlags[name] {
+				i++
+			}
+			continue
+		}
+
+		flags = append(flags, base[i])
+	}
+
+	return append(flags, overrides...)
+}
+
+// flagValue returns the index of the value of the flag at the given index,
+// which is either part of the flag itself or the next argument, along with the
+// prefix that comes before the value at that index.
+func flagValue(flags []string, index int) (int, string, bool) {
+	if before, _, found := strings.Cut(flags[index], "="); found {
+		return index, before + "=", true
+	}
+
+	if index+1 < len(flags) {
+		return index + 1, "", true
+	}
+
+	return 0, "", false
+}
+
+func flagName(flag string) (string, bool) {
+	if !strings.HasPrefix(flag, "-") {
+		return "", false
+	}
+
+	name := strings.TrimLeft(flag, "-")
+	name, _, _ = strings.Cut(name, "=")
+	return name, name != ""
+}
+
+// shouldDownloadModules reports whether the module dependencies of the module
+// in the given directory should be fetched into the module cache ahead of the
+// build. GOPATH and vendored applications do not make use of the module cache.
+func shouldDownloadModules(config GoBuildConfiguration, dir string) (bool, error) {
+	if config.GoModCache == "" {
+		return false, nil
+	}
+
+	hasGoMod, err := fs.Exists(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return false, fmt.Errorf("failed to check for go.mod: %w", err)
+	}
+
+	hasVendor, err := fs.Exists(filepath.Join(dir, "vendor", "modules.txt"))
+	if err != nil {
+		return false, fmt.Errorf("failed to check for vendor/modules.txt: %w", err)
+	}
+
+	return hasGoMod && !hasVendor, nil
+}
+
+func formatCommand(args []string) string {
+	printedArgs := []string{"go"}
+	for _, arg := range args {
+		printedArgs = append(printedArgs, formatArg(arg))
+	}
+
+	return strings.Join(printedArgs, " ")
 }
 
 func formatArg(arg string) string {
@@ -0,0 +1,468 @@
+package gobuild
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/licensecheck"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+const (
+	// LicenseManifestFileName is the name of the file in the targets layer
+	// that lists the licenses of the modules compiled into the binaries.
+	LicenseManifestFileName = "licenses.json"
+
+	unknownLicense = "UNKNOWN"
+)
+
+// licenseFilePrefixes are the prefixes of the files at the root of a module
+// that are copied into the manifest. They are the files that 'go mod vendor'
+// keeps along with the vendored packages. Only the files that are not notices
+// are used to identify the licenses.
+var (
+	licenseFilePrefixes = []string{"COPYING", "COPYRIGHT", "LICENCE", "LICENSE", "NOTICE", "PATENTS"}
+	noticeFilePrefixes  = []string{"NOTICE", "PATENTS"}
+)
+
+//go:generate faux --interface LicenseCollector --output fakes/license_collector.go
+type LicenseCollector interface {
+	Collect(config LicenseCollectionConfiguration) error
+}
+
+type LicenseCollectionConfiguration struct {
+	Binaries   []string
+	Workspace  string
+	Modules    []string
+	GoModCache string
+	Allow      []string
+	Deny       []string
+	Manifest   string
+}
+
+// ModuleLicense describes the licenses of a module that is compiled into one
+// or more of the binaries.
+type ModuleLicense struct {
+	Path     string        `json:"path"`
+	Version  string        `json:"version"`
+	Main     bool          `json:"main,omitempty"`
+	Binaries []string      `json:"binaries"`
+	Licenses []string      `json:"licenses"`
+	Files    []LicenseFile `json:"files,omitempty"`
+}
+
+type LicenseFile struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+type GoLicenseCollector struct {
+	executable Executable
+	logs       scribe.Emitter
+}
+
+func NewGoLicenseCollector(executable Executable, logs scribe.Emitter) GoLicenseCollector {
+	return GoLicenseCollector{
+		executable: executable,
+		logs:       logs,
+	}
+}
+
+// Collect determines the licenses of every module in the build information
+// of the binaries, along with the standard library, from the license files
+// in the source of the modules. The modules and the texts of their license
+// files are written to the manifest, and the collection fails when the
+// licenses of any module other than the main modules are not allowed by the
+// policy.
+func (c GoLicenseCollector) Collect(config LicenseCollectionConfiguration) error {
+	c.logs.Process("Collecting licenses")
+
+	sources, err := c.newModuleSources(config)
+	if err != nil {
+		return err
+	}
+
+	modules := map[string]*ModuleLicense{}
+	for _, binary := range config.Binaries {
+		info, err := buildinfo.ReadFile(binary)
+		if err != nil {
+			return fmt.Errorf("failed to read build information of %s: %w", binary, err)
+		}
+
+		version, _, _ := strings.Cut(info.GoVersion, " ")
+		compiled := []compiledModule{
+			{module: info.Main, dir: sources.workspaceModules[info.Main.Path], main: true},
+			{module: debug.Module{Path: "stdlib", Version: version}, dir: sources.goRoot},
+		}
+
+		for _, dependency := range info.Deps {
+			compiled = append(compiled, compiledModule{module: *dependency, dir: sources.dir(*dependency, info.Main.Path)})
+		}
+
+		for _, entry := range compiled {
+			m := entry.module
+			if m.Replace != nil {
+				m = *m.Replace
+			}
+
+			key := m.Path + "@" + m.Version
+			if existing, ok := modules[key]; ok {
+				existing.Binaries = append(existing.Binaries, binary)
+				continue
+			}
+
+			license := &ModuleLicense{
+				Path:     m.Path,
+				Version:  m.Version,
+				Main:     entry.main,
+				Binaries: []string{binary},
+			}
+
+			if entry.dir != "" {
+				license.Licenses, license.Files, err = readLicenses(entry.dir)
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(license.Licenses) == 0 {
+				license.Licenses = []string{unknownLicense}
+			}
+
+			modules[key] = license
+		}
+	}
+
+	var manifest []ModuleLicense
+	for _, license := range modules {
+		manifest = append(manifest, *license)
+	}
+
+	sort.Slice(manifest, func(i, j int) bool {
+		if manifest[i].Path != manifest[j].Path {
+			return manifest[i].Path < manifest[j].Path
+		}
+		return manifest[i].Version < manifest[j].Version
+	})
+
+	var violations []string
+	for _, license := range manifest {
+		if slices.Contains(license.Licenses, unknownLicense) {
+			c.logs.Action("No license found for %s", moduleVersion(license))
+		}
+
+		if license.Main {
+			continue
+		}
+
+		if disallowed := disallowedLicenses(license.Licenses, config.Allow, config.Deny); len(disallowed) > 0 {
+			violations = append(violations, fmt.Sprintf("%s: %s", moduleVersion(license), strings.Join(disallowed, ", ")))
+		}
+	}
+
+	err = writeLicenseManifest(config.Manifest, manifest)
+	if err != nil {
+		return err
+	}
+
+	c.logs.Subprocess("Wrote the licenses of %d modules to %s", len(manifest), config.Manifest)
+	c.logs.Break()
+
+	if len(violations) > 0 {
+		return packit.Fail.WithMessage("license policy violated by the following modules:\n  %s", strings.Join(violations, "\n  "))
+	}
+
+	return nil
+}
+
+// compiledModule is a module in the build information of a binary along
+// with the directory that holds its source.
+type compiledModule struct {
+	module debug.Module
+	dir    string
+	main   bool
+}
+
+// moduleSources locates the source of the modules compiled into the binaries.
+type moduleSources struct {
+	workspace        string
+	workspaceModules map[string]string
+	replacements     []localReplacement
+	vendored         bool
+	goModCache       string
+	goRoot           string
+}
+
+// localReplacement is a replace directive that replaces a module with a
+// directory. The directory is relative to the go.mod or go.work file that
+// declares it, and is already resolved against it.
+type localReplacement struct {
+	declaredBy string
+	path       string
+	replace    string
+	dir        string
+}
+
+func (c GoLicenseCollector) newModuleSources(config LicenseCollectionConfiguration) (moduleSources, error) {
+	sources := moduleSources{
+		workspace:        config.Workspace,
+		workspaceModules: map[string]string{},
+		goModCache:       config.GoModCache,
+	}
+
+	// The replacements of a go.work file apply to every module in it, and
+	// take precedence over those of the modules
+	goWorkPath := filepath.Join(config.Workspace, "go.work")
+	content, err := os.ReadFile(goWorkPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return moduleSources{}, fmt.Errorf("failed to read go.work: %w", err)
+	}
+
+	if err == nil {
+		goWork, err := modfile.ParseWork(goWorkPath, content, nil)
+		if err != nil {
+			return moduleSources{}, fmt.Errorf("failed to parse go.work: %w", err)
+		}
+
+		sources.replacements = append(sources.replacements, localReplacements("", config.Workspace, goWork.Replace)...)
+	}
+
+	// The main modules and the modules of a go.work file are built from the
+	// application source, and are found by the path in their go.mod files
+	for _, dir := range append([]string{config.Workspace}, config.Modules...) {
+		goModPath := filepath.Join(dir, "go.mod")
+		content, err := os.ReadFile(goModPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return moduleSources{}, fmt.Errorf("failed to read go.mod: %w", err)
+		}
+
+		goMod, err := modfile.Parse(goModPath, content, nil)
+		if err != nil {
+			return moduleSources{}, fmt.Errorf("failed to parse %s: %w", goModPath, err)
+		}
+
+		if goMod.Module == nil || goMod.Module.Mod.Path == "" {
+			continue
+		}
+
+		sources.workspaceModules[goMod.Module.Mod.Path] = dir
+		sources.replacements = append(sources.replacements, localReplacements(goMod.Module.Mod.Path, dir, goMod.Replace)...)
+	}
+
+	info, err := os.Stat(filepath.Join(config.Workspace, "vendor", "modules.txt"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return moduleSources{}, fmt.Errorf("failed to check for vendor/modules.txt: %w", err)
+	}
+	sources.vendored = info != nil
+
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	err = c.executable.Execute(pexec.Execution{
+		Args:   []string{"env", "GOROOT"},
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		return moduleSources{}, fmt.Errorf("failed to execute 'go env GOROOT': %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	sources.goRoot = strings.TrimSpace(stdout.String())
+
+	return sources, nil
+}
+
+func localReplacements(declaredBy, dir string, replaces []*modfile.Replace) []localReplacement {
+	var replacements []localReplacement
+	for _, replace := range replaces {
+		if replace.New.Version != "" {
+			continue
+		}
+
+		replacement := localReplacement{
+			declaredBy: declaredBy,
+			path:       replace.Old.Path,
+			replace:    replace.New.Path,
+			dir:        replace.New.Path,
+		}
+
+		if !filepath.IsAbs(replacement.dir) {
+			replacement.dir = filepath.Join(dir, filepath.FromSlash(replacement.dir))
+		}
+
+		replacements = append(replacements, replacement)
+	}
+
+	return replacements
+}
+
+// dir returns the directory that holds the source of the module of a binary
+// built from the given main module, or an empty string when it cannot be
+// found.
+func (s moduleSources) dir(m debug.Module, main string) string {
+	compiled := m
+	if m.Replace != nil {
+		compiled = *m.Replace
+	}
+
+	// Modules of a go.work file have no version of their own
+	if dir, ok := s.workspaceModules[compiled.Path]; ok && (compiled.Version == "" || compiled.Version == "(devel)") {
+		return dir
+	}
+
+	// Modules that are replaced by a directory have no version, and their path
+	// is relative to the file that declares the replacement. The build
+	// information only records the path as it is written, so it is looked up
+	// in the go.work file first, then in the go.mod of the main module.
+	if m.Replace != nil && (m.Replace.Version == "" || m.Replace.Version == "(devel)") {
+		for _, declaredBy := range []string{"", main} {
+			for _, replacement := range s.replacements {
+				if replacement.declaredBy == declaredBy && replacement.path == m.Path && replacement.replace == m.Replace.Path {
+					return replacement.dir
+				}
+			}
+		}
+
+		if filepath.IsAbs(m.Replace.Path) {
+			return m.Replace.Path
+		}
+		return filepath.Join(s.workspace, m.Replace.Path)
+	}
+
+	// Vendored modules are kept under their original path
+	if s.vendored {
+		dir := filepath.Join(s.workspace, "vendor", filepath.FromSlash(m.Path))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+
+	path, err := module.EscapePath(compiled.Path)
+	if err != nil {
+		return ""
+	}
+
+	version, err := module.EscapeVersion(compiled.Version)
+	if err != nil {
+		return ""
+	}
+
+	dir := filepath.Join(s.goModCache, filepath.FromSlash(path)+"@"+version)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir
+	}
+
+	return ""
+}
+
+// readLicenses returns the license files at the root of the module directory
+// and the SPDX identifiers of the licenses found in them.
+func readLicenses(dir string) ([]string, []LicenseFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, nil
+		}
+
+		return nil, nil, fmt.Errorf("failed to read licenses: %w", err)
+	}
+
+	var ids []string
+	var files []LicenseFile
+	for _, entry := range entries {
+		hasPrefix := func(prefix string) bool {
+			return strings.HasPrefix(strings.ToUpper(entry.Name()), prefix)
+		}
+
+		if !entry.Type().IsRegular() || !slices.ContainsFunc(licenseFilePrefixes, hasPrefix) {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read licenses: %w", err)
+		}
+
+		files = append(files, LicenseFile{
+			Name: entry.Name(),
+			Text: string(content),
+		})
+
+		if slices.ContainsFunc(noticeFilePrefixes, hasPrefix) {
+			continue
+		}
+
+		for _, match := range licensecheck.Scan(content).Match {
+			if !match.IsURL && !slices.Contains(ids, match.ID) {
+				ids = append(ids, match.ID)
+			}
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids, files, nil
+}
+
+// disallowedLicenses returns the licenses that are denied or, when there is
+// an allow list, that are not on it.
+func disallowedLicenses(licenses, allow, deny []string) []string {
+	var disallowed []string
+	for _, license := range licenses {
+		matches := func(id string) bool {
+			return strings.EqualFold(id, license)
+		}
+
+		if slices.ContainsFunc(deny, matches) || (len(allow) > 0 && !slices.ContainsFunc(allow, matches)) {
+			disallowed = append(disallowed, license)
+		}
+	}
+
+	return disallowed
+}
+
+func moduleVersion(license ModuleLicense) string {
+	if license.Version == "" {
+		return license.Path
+	}
+
+	return license.Path + "@" + license.Version
+}
+
+func writeLicenseManifest(path string, modules []ModuleLicense) error {
+	if modules == nil {
+		modules = []ModuleLicense{}
+	}
+
+	content, err := json.MarshalIndent(struct {
+		Modules []ModuleLicense `json:"modules"`
+	}{
+		Modules: modules,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode license manifest: %w", err)
+	}
+
+	err = os.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write license manifest: %w", err)
+	}
+
+	return nil
+}
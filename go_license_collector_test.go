@@ -0,0 +1,410 @@
+package gobuild_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	gobuild "github.com/paketo-buildpacks/go-build"
+	"github.com/paketo-buildpacks/go-build/fakes"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/sclevine/spec"
+	"golang.org/x/mod/module"
+
+	. "github.com/onsi/gomega"
+)
+
+const mitLicense = `MIT License
+
+Copyright (c) 2024 Some Author
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+`
+
+func testGoLicenseCollector(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		binDir     string
+		workspace  string
+		goModCache string
+		goRoot     string
+		layerPath  string
+		buildInfo  *debug.BuildInfo
+		gomega     debug.Module
+		executions []pexec.Execution
+
+		executable *fakes.Executable
+		logs       *bytes.Buffer
+
+		collector gobuild.GoLicenseCollector
+	)
+
+	readManifest := func() map[string]gobuild.ModuleLicense {
+		content, err := os.ReadFile(filepath.Join(layerPath, "licenses.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var manifest struct {
+			Modules []gobuild.ModuleLicense `json:"modules"`
+		}
+		Expect(json.Unmarshal(content, &manifest)).To(Succeed())
+
+		modules := map[string]gobuild.ModuleLicense{}
+		for _, m := range manifest.Modules {
+			modules[m.Path] = m
+		}
+
+		return modules
+	}
+
+	it.Before(func() {
+		var err error
+		binDir, err = os.MkdirTemp("", "bin")
+		Expect(err).NotTo(HaveOccurred())
+
+		workspace, err = os.MkdirTemp("", "workspace")
+		Expect(err).NotTo(HaveOccurred())
+
+		goModCache, err = os.MkdirTemp("", "gomodcache")
+		Expect(err).NotTo(HaveOccurred())
+
+		goRoot, err = os.MkdirTemp("", "goroot")
+		Expect(err).NotTo(HaveOccurred())
+
+		layerPath, err = os.MkdirTemp("", "layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		// The test binary is a Go binary with build information of its own
+		testBinary, err := os.Executable()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fs.Copy(testBinary, filepath.Join(binDir, "some-binary"))).To(Succeed())
+
+		var ok bool
+		buildInfo, ok = debug.ReadBuildInfo()
+		Expect(ok).To(BeTrue())
+
+		apacheLicense, err := os.ReadFile("LICENSE")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(workspace, "go.mod"), []byte(fmt.Sprintf("module %s\n", buildInfo.Main.Path)), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workspace, "LICENSE"), apacheLicense, 0600)).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(goRoot, "LICENSE"), []byte(mitLicense), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(goRoot, "NOTICE"), apacheLicense, 0600)).To(Succeed())
+
+		// Every module in the module cache is MIT licensed, except for gomega
+		// which has no license file
+		for _, dependency := range buildInfo.Deps {
+			if dependency.Replace != nil || dependency.Version == "" {
+				continue
+			}
+
+			if dependency.Path == "github.com/onsi/gomega" {
+				gomega = *dependency
+			}
+
+			path, err := module.EscapePath(dependency.Path)
+			Expect(err).NotTo(HaveOccurred())
+
+			dir := filepath.Join(goModCache, filepath.FromSlash(path)+"@"+dependency.Version)
+			Expect(os.MkdirAll(dir, os.ModePerm)).To(Succeed())
+
+			if dependency.Path != "github.com/onsi/gomega" {
+				Expect(os.WriteFile(filepath.Join(dir, "LICENSE.md"), []byte(mitLicense), 0600)).To(Succeed())
+			}
+		}
+		Expect(gomega.Path).NotTo(BeEmpty())
+
+		logs = bytes.NewBuffer(nil)
+
+		executions = nil
+		executable = &fakes.Executable{}
+		executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+			executions = append(executions, execution)
+			_, err := fmt.Fprintln(execution.Stdout, goRoot)
+			return err
+		}
+
+		collector = gobuild.NewGoLicenseCollector(executable, scribe.NewEmitter(logs))
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(binDir)).To(Succeed())
+		Expect(os.RemoveAll(workspace)).To(Succeed())
+		Expect(os.RemoveAll(goModCache)).To(Succeed())
+		Expect(os.RemoveAll(goRoot)).To(Succeed())
+		Expect(os.RemoveAll(layerPath)).To(Succeed())
+	})
+
+	it("writes the licenses of the modules compiled into the binaries to the manifest", func() {
+		err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+			Binaries:   []string{filepath.Join(binDir, "some-binary")},
+			Workspace:  workspace,
+			GoModCache: goModCache,
+			Manifest:   filepath.Join(layerPath, "licenses.json"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(executions).To(HaveLen(1))
+		Expect(executions[0].Args).To(Equal([]string{"env", "GOROOT"}))
+
+		modules := readManifest()
+		Expect(modules).To(HaveLen(len(buildInfo.Deps) + 2))
+
+		main := modules[buildInfo.Main.Path]
+		Expect(main.Main).To(BeTrue())
+		Expect(main.Binaries).To(Equal([]string{filepath.Join(binDir, "some-binary")}))
+		Expect(main.Licenses).To(Equal([]string{"Apache-2.0"}))
+		Expect(main.Files).To(HaveLen(1))
+		Expect(main.Files[0].Name).To(Equal("LICENSE"))
+		Expect(main.Files[0].Text).To(ContainSubstring("Apache License"))
+
+		stdlib := modules["stdlib"]
+		Expect(stdlib.Main).To(BeFalse())
+		Expect(stdlib.Version).To(Equal(strings.Fields(buildInfo.GoVersion)[0]))
+		// Notices are kept along with the licenses, but are not used to
+		// identify them
+		Expect(stdlib.Licenses).To(Equal([]string{"MIT"}))
+		Expect(stdlib.Files).To(HaveLen(2))
+		Expect(stdlib.Files[0]).To(Equal(gobuild.LicenseFile{Name: "LICENSE", Text: mitLicense}))
+		Expect(stdlib.Files[1].Name).To(Equal("NOTICE"))
+
+		Expect(modules["github.com/onsi/gomega"]).To(Equal(gobuild.ModuleLicense{
+			Path:     "github.com/onsi/gomega",
+			Version:  gomega.Version,
+			Binaries: []string{filepath.Join(binDir, "some-binary")},
+			Licenses: []string{"UNKNOWN"},
+		}))
+
+		sclevineSpec := modules["github.com/sclevine/spec"]
+		Expect(sclevineSpec.Licenses).To(Equal([]string{"MIT"}))
+		Expect(sclevineSpec.Files).To(Equal([]gobuild.LicenseFile{{Name: "LICENSE.md", Text: mitLicense}}))
+
+		Expect(logs.String()).To(ContainSubstring("Collecting licenses"))
+		Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("No license found for github.com/onsi/gomega@%s", gomega.Version)))
+		Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("Wrote the licenses of %d modules to %s", len(buildInfo.Deps)+2, filepath.Join(layerPath, "licenses.json"))))
+	})
+
+	context("when there are several binaries", func() {
+		it.Before(func() {
+			Expect(fs.Copy(filepath.Join(binDir, "some-binary"), filepath.Join(binDir, "other-binary"))).To(Succeed())
+		})
+
+		it("lists each module once with every binary it is compiled into", func() {
+			err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+				Binaries:   []string{filepath.Join(binDir, "some-binary"), filepath.Join(binDir, "other-binary")},
+				Workspace:  workspace,
+				GoModCache: goModCache,
+				Manifest:   filepath.Join(layerPath, "licenses.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			modules := readManifest()
+			Expect(modules).To(HaveLen(len(buildInfo.Deps) + 2))
+			Expect(modules["github.com/sclevine/spec"].Binaries).To(Equal([]string{
+				filepath.Join(binDir, "some-binary"),
+				filepath.Join(binDir, "other-binary"),
+			}))
+		})
+	})
+
+	context("when the modules are vendored", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workspace, "vendor", "github.com", "onsi", "gomega"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workspace, "vendor", "modules.txt"), nil, 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workspace, "vendor", "github.com", "onsi", "gomega", "LICENSE"), []byte(mitLicense), 0600)).To(Succeed())
+		})
+
+		it("reads the licenses from the vendor directory", func() {
+			err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+				Binaries:   []string{filepath.Join(binDir, "some-binary")},
+				Workspace:  workspace,
+				GoModCache: goModCache,
+				Manifest:   filepath.Join(layerPath, "licenses.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(readManifest()["github.com/onsi/gomega"].Licenses).To(Equal([]string{"MIT"}))
+		})
+	})
+
+	context("when the main module is in a nested directory", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workspace, "services", "api"), os.ModePerm)).To(Succeed())
+			Expect(os.Rename(filepath.Join(workspace, "go.mod"), filepath.Join(workspace, "services", "api", "go.mod"))).To(Succeed())
+			Expect(os.Rename(filepath.Join(workspace, "LICENSE"), filepath.Join(workspace, "services", "api", "LICENSE"))).To(Succeed())
+		})
+
+		it("reads the licenses from the directory of that module", func() {
+			err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+				Binaries:   []string{filepath.Join(binDir, "some-binary")},
+				Workspace:  workspace,
+				Modules:    []string{workspace, filepath.Join(workspace, "services", "api")},
+				GoModCache: goModCache,
+				Manifest:   filepath.Join(layerPath, "licenses.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(readManifest()[buildInfo.Main.Path].Licenses).To(Equal([]string{"Apache-2.0"}))
+		})
+	})
+
+	context("when a nested module replaces a dependency with a directory", func() {
+		it.Before(func() {
+			for path, content := range map[string]string{
+				"services/api/go.mod":  "module example.com/api\n\ngo 1.21\n\nrequire example.com/shared v0.0.0\n\nreplace example.com/shared => ../../libs/shared\n",
+				"services/api/main.go": "package main\n\nimport \"example.com/shared\"\n\nfunc main() { shared.Run() }\n",
+				"libs/shared/go.mod":   "module example.com/shared\n\ngo 1.21\n",
+				"libs/shared/run.go":   "package shared\n\nfunc Run() {}\n",
+				"libs/shared/LICENSE":  mitLicense,
+			} {
+				Expect(os.MkdirAll(filepath.Join(workspace, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workspace, path), []byte(content), 0600)).To(Succeed())
+			}
+
+			// The build information only records the replacement as it is
+			// written in the go.mod file, so a binary is built with it
+			command := exec.Command("go", "build", "-o", filepath.Join(binDir, "api"), ".")
+			command.Dir = filepath.Join(workspace, "services", "api")
+			command.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod", "GOPROXY=off", "GOTOOLCHAIN=local")
+			output, err := command.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(output))
+		})
+
+		it("reads the licenses from the directory relative to that module", func() {
+			err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+				Binaries:   []string{filepath.Join(binDir, "api")},
+				Workspace:  workspace,
+				Modules:    []string{workspace, filepath.Join(workspace, "services", "api")},
+				GoModCache: goModCache,
+				Manifest:   filepath.Join(layerPath, "licenses.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			modules := readManifest()
+			Expect(modules["example.com/api"].Main).To(BeTrue())
+			Expect(modules["../../libs/shared"].Licenses).To(Equal([]string{"MIT"}))
+		})
+	})
+
+	context("when only some licenses are allowed", func() {
+		it("fails for the modules with other licenses", func() {
+			err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+				Binaries:   []string{filepath.Join(binDir, "some-binary")},
+				Workspace:  workspace,
+				GoModCache: goModCache,
+				Allow:      []string{"mit"},
+				Manifest:   filepath.Join(layerPath, "licenses.json"),
+			})
+			Expect(err).To(MatchError(HavePrefix("license policy violated by the following modules:\n")))
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("github.com/onsi/gomega@%s: UNKNOWN", gomega.Version))))
+
+			// The licenses of the main module are not subject to the policy
+			Expect(err).NotTo(MatchError(ContainSubstring("Apache-2.0")))
+			Expect(err).NotTo(MatchError(ContainSubstring("stdlib")))
+
+			Expect(filepath.Join(layerPath, "licenses.json")).To(BeARegularFile())
+		})
+
+		context("when modules without a license are allowed", func() {
+			it("succeeds", func() {
+				err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+					Binaries:   []string{filepath.Join(binDir, "some-binary")},
+					Workspace:  workspace,
+					GoModCache: goModCache,
+					Allow:      []string{"MIT", "UNKNOWN"},
+					Manifest:   filepath.Join(layerPath, "licenses.json"),
+				})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+	})
+
+	context("when some licenses are denied", func() {
+		it("fails for the modules with those licenses", func() {
+			err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+				Binaries:   []string{filepath.Join(binDir, "some-binary")},
+				Workspace:  workspace,
+				GoModCache: goModCache,
+				Deny:       []string{"MIT"},
+				Manifest:   filepath.Join(layerPath, "licenses.json"),
+			})
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("stdlib@%s: MIT", strings.Fields(buildInfo.GoVersion)[0]))))
+			Expect(err).NotTo(MatchError(ContainSubstring("github.com/onsi/gomega")))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when GOROOT cannot be determined", func() {
+			it.Before(func() {
+				executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+					fmt.Fprintln(execution.Stderr, "go: some error")
+					return errors.New("exit status 1")
+				}
+			})
+
+			it("returns an error", func() {
+				err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+					Binaries:   []string{filepath.Join(binDir, "some-binary")},
+					Workspace:  workspace,
+					GoModCache: goModCache,
+					Manifest:   filepath.Join(layerPath, "licenses.json"),
+				})
+				Expect(err).To(MatchError("failed to execute 'go env GOROOT': exit status 1: go: some error"))
+			})
+		})
+
+		context("when a binary has no build information", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(binDir, "some-script"), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+					Binaries:   []string{filepath.Join(binDir, "some-script")},
+					Workspace:  workspace,
+					GoModCache: goModCache,
+					Manifest:   filepath.Join(layerPath, "licenses.json"),
+				})
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("failed to read build information of %s:", filepath.Join(binDir, "some-script")))))
+			})
+		})
+
+		context("when the manifest cannot be written", func() {
+			it("returns an error", func() {
+				err := collector.Collect(gobuild.LicenseCollectionConfiguration{
+					Binaries:   []string{filepath.Join(binDir, "some-binary")},
+					Workspace:  workspace,
+					GoModCache: goModCache,
+					Manifest:   filepath.Join(layerPath, "missing", "licenses.json"),
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to write license manifest:")))
+			})
+		})
+	})
+}
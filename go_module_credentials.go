@@ -0,0 +1,263 @@
+package gobuild
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+)
+
+const (
+	// GitCredentialsBindingType is the type of service bindings that contain
+	// credentials in the git-credential-store format.
+	GitCredentialsBindingType = "git-credentials"
+
+	// NetrcBindingType is the type of service bindings that contain a .netrc
+	// file, which the go command uses to authenticate with module proxies.
+	NetrcBindingType = "netrc"
+
+	// SSHBindingType is the type of service bindings that contain a private
+	// key for fetching modules from git repositories over SSH.
+	SSHBindingType = "ssh"
+)
+
+//go:generate faux --interface BindingResolver --output fakes/binding_resolver.go
+type BindingResolver interface {
+	Resolve(typ, provider, platformDir string) ([]servicebindings.Binding, error)
+}
+
+// moduleCredentials holds the files and environment that give the go command
+// access to private modules. The files live in a temporary directory outside
+// of any layer and must be removed once the build is done with them.
+type moduleCredentials struct {
+	dir      string
+	env      []string
+	bindings []servicebindings.Binding
+}
+
+func (c moduleCredentials) Remove() error {
+	if c.dir == "" {
+		return nil
+	}
+
+	return os.RemoveAll(c.dir)
+}
+
+// setupModuleCredentials converts the private module bindings into the
+// environment of the go command. The binding values are only ever written to
+// files in a temporary directory so that they do not show up in the build
+// logs or the environment of the image.
+func setupModuleCredentials(resolver BindingResolver, platformPath string) (moduleCredentials, error) {
+	var credentials moduleCredentials
+	bindings := map[string][]servicebindings.Binding{}
+	for _, typ := range []string{GitCredentialsBindingType, NetrcBindingType, SSHBindingType} {
+		resolved, err := resolver.Resolve(typ, "", platformPath)
+		if err != nil {
+			return moduleCredentials{}, fmt.Errorf("failed to resolve %s bindings: %w", typ, err)
+		}
+
+		bindings[typ] = resolved
+		credentials.bindings = append(credentials.bindings, resolved...)
+	}
+
+	if len(credentials.bindings) == 0 {
+		return moduleCredentials{}, nil
+	}
+
+	var err error
+	credentials.dir, err = os.MkdirTemp("", "go-build-credentials")
+	if err != nil {
+		return moduleCredentials{}, fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+
+	err = credentials.setup(bindings)
+	if err != nil {
+		_ = credentials.Remove()
+		return moduleCredentials{}, err
+	}
+
+	return credentials, nil
+}
+
+func (c *moduleCredentials) setup(bindings map[string][]servicebindings.Binding) error {
+	var gitConfig []string
+
+	if len(bindings[GitCredentialsBindingType]) > 0 {
+		content, err := concatEntries(bindings[GitCredentialsBindingType], "credentials")
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(c.dir, "git-credentials")
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			return fmt.Errorf("failed to write git credentials: %w", err)
+		}
+
+		gitConfig = append(gitConfig, "[credential]", fmt.Sprintf("\thelper = %q", fmt.Sprintf("store --file=%s", path)))
+	}
+
+	if len(bindings[NetrcBindingType]) > 0 {
+		content, err := concatEntries(bindings[NetrcBindingType], "netrc")
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(c.dir, "netrc")
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			return fmt.Errorf("failed to write netrc: %w", err)
+		}
+
+		c.env = append(c.env, fmt.Sprintf("NETRC=%s", path))
+
+		for _, binding := range bindings[NetrcBindingType] {
+			goProxy, ok, err := optionalEntry(binding, "goproxy")
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				c.env = append(c.env, fmt.Sprintf("GOPROXY=%s", strings.TrimSpace(goProxy)))
+			}
+		}
+	}
+
+	if len(bindings[SSHBindingType]) > 0 {
+		sshCommand := []string{"ssh", "-o", "IdentitiesOnly=yes"}
+		var knownHosts []string
+		for index, binding := range bindings[SSHBindingType] {
+			key, err := requiredEntry(binding, "ssh-privatekey")
+			if err != nil {
+				return err
+			}
+
+			// ssh refuses to use keys that are missing the trailing newline
+			if !strings.HasSuffix(key, "\n") {
+				key += "\n"
+			}
+
+			path := filepath.Join(c.dir, fmt.Sprintf("ssh-key-%d", index))
+			err = os.WriteFile(path, []byte(key), 0600)
+			if err != nil {
+				return fmt.Errorf("failed to write ssh key: %w", err)
+			}
+			sshCommand = append(sshCommand, "-i", path)
+
+			// Host keys are never accepted on first use, which would let anyone
+			// in the network path of the build serve the private modules
+			hosts, err := requiredEntry(binding, "known_hosts")
+			if err != nil {
+				return err
+			}
+			knownHosts = append(knownHosts, strings.TrimSuffix(hosts, "\n"))
+
+			rewrites, _, err := optionalEntry(binding, "hosts")
+			if err != nil {
+				return err
+			}
+
+			for _, host := range strings.Fields(rewrites) {
+				gitConfig = append(gitConfig,
+					fmt.Sprintf("[url %q]", fmt.Sprintf("ssh://git@%s/", host)),
+					fmt.Sprintf("\tinsteadOf = https://%s/", host),
+				)
+			}
+		}
+
+		path := filepath.Join(c.dir, "known_hosts")
+		err := os.WriteFile(path, []byte(strings.Join(knownHosts, "\n")+"\n"), 0600)
+		if err != nil {
+			return fmt.Errorf("failed to write known hosts: %w", err)
+		}
+		sshCommand = append(sshCommand, "-o", fmt.Sprintf("UserKnownHostsFile=%s", path), "-o", "StrictHostKeyChecking=yes")
+
+		gitConfig = append(gitConfig, "[core]", fmt.Sprintf("\tsshCommand = %q", strings.Join(sshCommand, " ")))
+	}
+
+	if len(gitConfig) > 0 {
+		path := filepath.Join(c.dir, "gitconfig")
+		err := os.WriteFile(path, []byte(strings.Join(gitConfig, "\n")+"\n"), 0600)
+		if err != nil {
+			return fmt.Errorf("failed to write git config: %w", err)
+		}
+
+		c.env = append(c.env, fmt.Sprintf("GIT_CONFIG_GLOBAL=%s", path), "GIT_TERMINAL_PROMPT=0")
+	}
+
+	// Any of the bindings can list the module path patterns that are private
+	for _, variable := range []struct{ name, entry string }{
+		{name: "GOPRIVATE", entry: "goprivate"},
+		{name: "GONOSUMDB", entry: "gonosumdb"},
+	} {
+		var patterns []string
+		for _, binding := range c.bindings {
+			value, _, err := optionalEntry(binding, variable.entry)
+			if err != nil {
+				return err
+			}
+
+			patterns = append(patterns, strings.FieldsFunc(value, func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})...)
+		}
+
+		if len(patterns) == 0 {
+			continue
+		}
+
+		if existing := os.Getenv(variable.name); existing != "" {
+			patterns = append([]string{existing}, patterns...)
+		}
+
+		c.env = append(c.env, fmt.Sprintf("%s=%s", variable.name, strings.Join(patterns, ",")))
+	}
+
+	return nil
+}
+
+// concatEntries joins the given entry of every binding so that several
+// bindings of the same type can be used at once.
+func concatEntries(bindings []servicebindings.Binding, name string) (string, error) {
+	var contents []string
+	for _, binding := range bindings {
+		content, err := requiredEntry(binding, name)
+		if err != nil {
+			return "", err
+		}
+
+		contents = append(contents, strings.TrimRight(content, "\n"))
+	}
+
+	return strings.Join(contents, "\n") + "\n", nil
+}
+
+func requiredEntry(binding servicebindings.Binding, name string) (string, error) {
+	value, ok, err := optionalEntry(binding, name)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "", fmt.Errorf("%s binding %q is missing the %q entry", binding.Type, binding.Name, name)
+	}
+
+	return value, nil
+}
+
+func optionalEntry(binding servicebindings.Binding, name string) (string, bool, error) {
+	entry, ok := binding.Entries[name]
+	if !ok {
+		return "", false, nil
+	}
+
+	value, err := entry.ReadString()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %q entry of %s binding %q: %w", name, binding.Type, binding.Name, err)
+	}
+
+	return value, true, nil
+}
@@ -0,0 +1,143 @@
+package gobuild
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// validateModulePatterns checks that the patterns given for the module policy
+// in the named setting are valid glob patterns.
+func validateModulePatterns(name string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid module pattern %q in %s: %w", pattern, name, err)
+		}
+	}
+
+	return nil
+}
+
+// checkModulePolicy checks the requirements and replacements in the go.mod
+// files of every module of the build against the module policy of the
+// configuration. Local replacements may point anywhere within the application
+// root, which can be above the workspace when BP_GO_WORKDIR is set.
+func checkModulePolicy(root string, moduleDirs []string, configuration BuildConfiguration) error {
+	if len(configuration.ModulesDeny) == 0 && len(configuration.ModulesDenyPseudoVersions) == 0 &&
+		!configuration.ModulesDenyExternalReplace && !configuration.ModulesRequireToolchain {
+		return nil
+	}
+
+	var violations []string
+	for _, dir := range moduleDirs {
+		goModPath := filepath.Join(dir, "go.mod")
+		content, err := os.ReadFile(goModPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return fmt.Errorf("failed to read go.mod: %w", err)
+		}
+
+		goMod, err := modfile.Parse(goModPath, content, nil)
+		if err != nil {
+			return fmt.Errorf("failed to parse go.mod: %w", err)
+		}
+
+		violations = append(violations, moduleViolations(goMod, dir, root, configuration)...)
+	}
+
+	if len(violations) > 0 {
+		return packit.Fail.WithMessage("module policy violated by the following modules:\n  %s", strings.Join(violations, "\n  "))
+	}
+
+	return nil
+}
+
+func moduleViolations(goMod *modfile.File, dir, root string, configuration BuildConfiguration) []string {
+	var violations []string
+
+	name := dir
+	if goMod.Module != nil {
+		name = goMod.Module.Mod.Path
+	}
+
+	if configuration.ModulesRequireToolchain && goMod.Toolchain == nil {
+		violations = append(violations, fmt.Sprintf("%s: go.mod does not list a toolchain", name))
+	}
+
+	// Replaced requirements are not built, often carrying a placeholder
+	// version, while replacements that point to another module version bring
+	// that module into the build, so those are checked instead
+	var versions []module.Version
+	for _, require := range goMod.Require {
+		replaced := slices.ContainsFunc(goMod.Replace, func(replace *modfile.Replace) bool {
+			return replace.Old.Path == require.Mod.Path && (replace.Old.Version == "" || replace.Old.Version == require.Mod.Version)
+		})
+
+		if !replaced {
+			versions = append(versions, require.Mod)
+		}
+	}
+
+	for _, replace := range goMod.Replace {
+		if replace.New.Version != "" {
+			versions = append(versions, replace.New)
+			continue
+		}
+
+		// A local replacement is still built as the module it replaces
+		versions = append(versions, module.Version{Path: replace.Old.Path})
+
+		if !configuration.ModulesDenyExternalReplace {
+			continue
+		}
+
+		target := replace.New.Path
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+
+		rel, err := filepath.Rel(root, target)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			violations = append(violations, fmt.Sprintf("%s => %s: replaced by a local path outside the application", replace.Old.String(), replace.New.Path))
+		}
+	}
+
+	for _, version := range versions {
+		if pattern, ok := matchModulePattern(configuration.ModulesDeny, version.Path); ok {
+			violations = append(violations, fmt.Sprintf("%s: matches the denied module pattern %q", version.String(), pattern))
+		}
+
+		if !module.IsPseudoVersion(version.Version) {
+			continue
+		}
+
+		if pattern, ok := matchModulePattern(configuration.ModulesDenyPseudoVersions, version.Path); ok {
+			violations = append(violations, fmt.Sprintf("%s: uses a pseudo-version, which is denied for %q", version.String(), pattern))
+		}
+	}
+
+	return violations
+}
+
+// matchModulePattern returns the first of the patterns that matches the
+// module path. Patterns match path prefixes like those in GOPRIVATE.
+func matchModulePattern(patterns []string, modulePath string) (string, bool) {
+	for _, pattern := range patterns {
+		if module.MatchPrefixPatterns(pattern, modulePath) {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
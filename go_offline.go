@@ -0,0 +1,125 @@
+package gobuild
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// GoModuleMirrorBindingType is the type of service bindings that provide a
+// module mirror for offline builds. The mirror is a directory in the layout of
+// a module proxy, such as a copy of $GOMODCACHE/cache/download.
+const GoModuleMirrorBindingType = "go-module-mirror"
+
+// offlineMode describes where the modules of an offline build come from.
+type offlineMode struct {
+	vendored bool
+	mirrors  []string
+	sumDB    bool
+}
+
+// resolveOfflineMode prefers the vendor directory of the application and
+// falls back to the module mirror bindings. Without either of them only the
+// modules that are already in the module cache can be used.
+func resolveOfflineMode(resolver BindingResolver, workspace, platformPath string) (offlineMode, error) {
+	vendored, err := fs.Exists(filepath.Join(workspace, "vendor", "modules.txt"))
+	if err != nil {
+		return offlineMode{}, fmt.Errorf("failed to check for vendor/modules.txt: %w", err)
+	}
+
+	if vendored {
+		return offlineMode{vendored: true}, nil
+	}
+
+	bindings, err := resolver.Resolve(GoModuleMirrorBindingType, "", platformPath)
+	if err != nil {
+		return offlineMode{}, fmt.Errorf("failed to resolve %s bindings: %w", GoModuleMirrorBindingType, err)
+	}
+
+	var mode offlineMode
+	for _, binding := range bindings {
+		mirror := filepath.Join(binding.Path, "mirror")
+		info, err := os.Stat(mirror)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return offlineMode{}, fmt.Errorf("failed to check for module mirror: %w", err)
+		}
+
+		if info == nil || !info.IsDir() {
+			return offlineMode{}, fmt.Errorf("%s binding %q must contain a \"mirror\" directory", binding.Type, binding.Name)
+		}
+
+		mode.mirrors = append(mode.mirrors, (&url.URL{Scheme: "file", Path: mirror}).String())
+
+		// The go command verifies checksums through the proxy when the proxy
+		// serves the checksum database
+		hasSumDB, err := fs.Exists(filepath.Join(mirror, "sumdb"))
+		if err != nil {
+			return offlineMode{}, fmt.Errorf("failed to check for checksum database snapshot: %w", err)
+		}
+
+		if hasSumDB {
+			mode.sumDB = true
+		}
+	}
+
+	return mode, nil
+}
+
+// env returns the environment that keeps the go command from reaching the
+// network. Downloads from anywhere other than the mirrors fail with the error
+// of the setting that disallows them.
+func (m offlineMode) env() []string {
+	proxy := "off"
+	if len(m.mirrors) > 0 {
+		proxy = strings.Join(m.mirrors, ",")
+	}
+
+	env := []string{
+		fmt.Sprintf("GOPROXY=%s", proxy),
+		"GONOPROXY=none",
+		"GOVCS=*:off",
+		"GOTOOLCHAIN=local",
+	}
+
+	// Without a checksum database snapshot the go.sum file of the application
+	// is the only source of checksums
+	if !m.sumDB {
+		env = append(env, "GOSUMDB=off")
+	}
+
+	return env
+}
+
+func (m offlineMode) goFlags() []string {
+	if m.vendored {
+		return []string{"-mod=vendor"}
+	}
+
+	return nil
+}
+
+func (m offlineMode) descriptions() []string {
+	switch {
+	case m.vendored:
+		return []string{"Building offline from the vendor directory"}
+
+	case len(m.mirrors) > 0:
+		checksums := "go.sum"
+		if m.sumDB {
+			checksums = "the checksum database snapshot in the module mirror"
+		}
+
+		return []string{
+			fmt.Sprintf("Building offline from the module mirror %s", strings.Join(m.mirrors, ", ")),
+			fmt.Sprintf("Verifying module checksums against %s", checksums),
+		}
+
+	default:
+		return []string{"Building offline without a vendor directory or module mirror, only cached modules are available"}
+	}
+}
@@ -0,0 +1,239 @@
+package gobuild
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/pprof/profile"
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	// GoPGOProfileBindingType is the type of service bindings that provide the
+	// CPU profile for profile-guided optimization in a "default.pgo" entry.
+	GoPGOProfileBindingType = "go-pgo-profile"
+
+	// DefaultPGOProfileName is the name of the profile that the go command uses
+	// for a main package when it is found in the directory of the package.
+	DefaultPGOProfileName = "default.pgo"
+
+	// PGOOff disables profile-guided optimization, including the default.pgo
+	// files of the main packages.
+	PGOOff = "off"
+)
+
+// preprocessedProfileHeader starts the profiles that have been converted by
+// 'go tool preprofile', which the go command accepts as well.
+const preprocessedProfileHeader = "GO PREPROFILE V1\n"
+
+//go:generate faux --interface ProfileResolver --output fakes/profile_resolver.go
+type ProfileResolver interface {
+	Resolve(config ProfileConfiguration) (ProfileSet, error)
+}
+
+type ProfileConfiguration struct {
+	Workspace           string
+	Targets             []string
+	Flags               []string
+	TargetConfiguration map[string]TargetConfiguration
+	WorkspaceUseModules []string
+	Profile             string
+	PlatformPath        string
+}
+
+// ProfileSet describes the profiles that the targets are built with.
+type ProfileSet struct {
+	// Flag is the value of the -pgo flag, which is empty when the go command
+	// should use the default.pgo file of every main package.
+	Flag string
+
+	// Targets maps the targets that are built with a profile to its path.
+	Targets map[string]string
+
+	// Digest is a checksum of the content of the profiles, which changes
+	// whenever one of them does.
+	Digest string
+}
+
+type GoProfileResolver struct {
+	bindings BindingResolver
+}
+
+func NewGoProfileResolver(bindings BindingResolver) GoProfileResolver {
+	return GoProfileResolver{
+		bindings: bindings,
+	}
+}
+
+// Resolve determines the profile of every target. A profile given by the
+// configuration, or else by a PGO profile binding, applies to all targets.
+// Without either, targets are built with the default.pgo file in their
+// directory when there is one. A -pgo flag in the build flags of a target
+// takes precedence over the binding, as the go command uses it instead, but
+// cannot be combined with a configured profile. Every profile is parsed to
+// make sure that the build does not fail on it.
+func (r GoProfileResolver) Resolve(config ProfileConfiguration) (ProfileSet, error) {
+	set := ProfileSet{
+		Targets: map[string]string{},
+	}
+
+	flags := map[string]string{}
+	for _, target := range config.Targets {
+		value, ok := pgoFlag(mergeFlags(config.Flags, config.TargetConfiguration[target].Flags))
+		if !ok {
+			continue
+		}
+
+		if config.Profile != "" {
+			return ProfileSet{}, packit.Fail.WithMessage("BP_GO_PGO cannot be combined with the -pgo build flag of target %s", target)
+		}
+
+		flags[target] = value
+	}
+
+	if config.Profile == PGOOff {
+		set.Flag = PGOOff
+		return set, nil
+	}
+
+	// The go command resolves a relative -pgo path against the directory that
+	// it runs in, which is the root of the module of the target
+	dirs := map[string]string{}
+	if len(flags) > 0 {
+		modules, err := findTargetModules(GoBuildConfiguration{
+			Workspace:           config.Workspace,
+			Targets:             config.Targets,
+			WorkspaceUseModules: config.WorkspaceUseModules,
+		})
+		if err != nil {
+			return ProfileSet{}, err
+		}
+
+		for _, module := range modules {
+			for _, target := range module.targets {
+				dirs[target] = module.dir
+			}
+		}
+	}
+
+	shared := config.Profile
+	if shared == "" {
+		bindings, err := r.bindings.Resolve(GoPGOProfileBindingType, "", config.PlatformPath)
+		if err != nil {
+			return ProfileSet{}, fmt.Errorf("failed to resolve %s bindings: %w", GoPGOProfileBindingType, err)
+		}
+
+		switch len(bindings) {
+		case 0:
+		case 1:
+			if _, ok := bindings[0].Entries[DefaultPGOProfileName]; !ok {
+				return ProfileSet{}, fmt.Errorf("%s binding %q must contain a %q entry", bindings[0].Type, bindings[0].Name, DefaultPGOProfileName)
+			}
+
+			shared = filepath.Join(bindings[0].Path, DefaultPGOProfileName)
+		default:
+			return ProfileSet{}, fmt.Errorf("expected at most one %s binding, found %d", GoPGOProfileBindingType, len(bindings))
+		}
+	}
+
+	set.Flag = shared
+
+	hash := sha256.New()
+	for _, target := range config.Targets {
+		path := shared
+		if value, ok := flags[target]; ok {
+			switch value {
+			case PGOOff:
+				continue
+			case "auto":
+				path = ""
+			default:
+				path = value
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(dirs[target], path)
+				}
+			}
+		}
+
+		// Only the default.pgo files are optional
+		required := path != ""
+		if path == "" {
+			path = filepath.Join(config.Workspace, target, DefaultPGOProfileName)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && !required {
+				continue
+			}
+
+			return ProfileSet{}, fmt.Errorf("failed to read PGO profile: %w", err)
+		}
+
+		err = validateProfile(content)
+		if err != nil {
+			return ProfileSet{}, packit.Fail.WithMessage("PGO profile %s cannot be used: %w", path, err)
+		}
+
+		set.Targets[target] = path
+
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(hash, "%s\x00%s\n", target, hex.EncodeToString(sum[:]))
+	}
+
+	if len(set.Targets) > 0 {
+		set.Digest = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	return set, nil
+}
+
+// pgoFlag returns the value of the last -pgo flag, which is the one that the
+// go command uses.
+func pgoFlag(flags []string) (string, bool) {
+	var value string
+	var found bool
+	for i := 0; i < len(flags); i++ {
+		if name, ok := flagName(flags[i]); !ok || name != "pgo" {
+			continue
+		}
+
+		index, prefix, ok := flagValue(flags, i)
+		if !ok {
+			continue
+		}
+
+		value, found = strings.TrimPrefix(flags[index], prefix), true
+		i = index
+	}
+
+	return value, found
+}
+
+// validateProfile checks that the profile can be used by the compiler, which
+// requires a pprof profile with CPU samples or a preprocessed profile.
+func validateProfile(content []byte) error {
+	if bytes.HasPrefix(content, []byte(preprocessedProfileHeader)) {
+		return nil
+	}
+
+	p, err := profile.Parse(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	for _, sampleType := range p.SampleType {
+		if (sampleType.Type == "samples" && sampleType.Unit == "count") ||
+			(sampleType.Type == "cpu" && sampleType.Unit == "nanoseconds") {
+			return nil
+		}
+	}
+
+	return errors.New("it does not contain CPU samples")
+}
@@ -0,0 +1,407 @@
+package gobuild_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	gobuild "github.com/paketo-buildpacks/go-build"
+	"github.com/paketo-buildpacks/go-build/fakes"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testGoProfileResolver(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workspace  string
+		cpuProfile []byte
+
+		bindingResolver *fakes.BindingResolver
+
+		resolver gobuild.GoProfileResolver
+	)
+
+	writeProfile := func(path string, sampleTypes ...*profile.ValueType) []byte {
+		buffer := bytes.NewBuffer(nil)
+		Expect((&profile.Profile{
+			SampleType: sampleTypes,
+			PeriodType: sampleTypes[len(sampleTypes)-1],
+			Period:     10000000,
+		}).Write(buffer)).To(Succeed())
+
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(path, buffer.Bytes(), 0600)).To(Succeed())
+
+		return buffer.Bytes()
+	}
+
+	it.Before(func() {
+		workspace = t.TempDir()
+
+		cpuProfile = writeProfile(filepath.Join(workspace, "cmd", "api", "default.pgo"),
+			&profile.ValueType{Type: "samples", Unit: "count"},
+			&profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		)
+		Expect(os.MkdirAll(filepath.Join(workspace, "cmd", "worker"), os.ModePerm)).To(Succeed())
+
+		bindingResolver = &fakes.BindingResolver{}
+
+		resolver = gobuild.NewGoProfileResolver(bindingResolver)
+	})
+
+	it("uses the default.pgo files of the targets that have one", func() {
+		profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+			Workspace:    workspace,
+			Targets:      []string{"./cmd/api", "./cmd/worker"},
+			PlatformPath: "some-platform-path",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(profiles.Flag).To(BeEmpty())
+		Expect(profiles.Targets).To(Equal(map[string]string{
+			"./cmd/api": filepath.Join(workspace, "cmd", "api", "default.pgo"),
+		}))
+		Expect(profiles.Digest).To(MatchRegexp(`^[0-9a-f]{64}$`))
+
+		Expect(bindingResolver.ResolveCall.Receives.Typ).To(Equal("go-pgo-profile"))
+		Expect(bindingResolver.ResolveCall.Receives.Provider).To(BeEmpty())
+		Expect(bindingResolver.ResolveCall.Receives.PlatformDir).To(Equal("some-platform-path"))
+	})
+
+	it("changes the digest when a profile changes", func() {
+		config := gobuild.ProfileConfiguration{
+			Workspace: workspace,
+			Targets:   []string{"./cmd/api"},
+		}
+
+		first, err := resolver.Resolve(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		writeProfile(filepath.Join(workspace, "cmd", "api", "default.pgo"), &profile.ValueType{Type: "cpu", Unit: "nanoseconds"})
+
+		second, err := resolver.Resolve(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.Digest).NotTo(Equal(first.Digest))
+	})
+
+	context("when none of the targets have a profile", func() {
+		it("builds without PGO", func() {
+			profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+				Workspace: workspace,
+				Targets:   []string{"./cmd/worker"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(profiles).To(Equal(gobuild.ProfileSet{
+				Targets: map[string]string{},
+			}))
+		})
+	})
+
+	context("when the profile has been preprocessed", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workspace, "cmd", "worker", "default.pgo"), []byte("GO PREPROFILE V1\nmain.handle\nmain.parse\n0 100\n"), 0600)).To(Succeed())
+		})
+
+		it("uses it", func() {
+			profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+				Workspace: workspace,
+				Targets:   []string{"./cmd/worker"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(profiles.Targets).To(HaveKeyWithValue("./cmd/worker", filepath.Join(workspace, "cmd", "worker", "default.pgo")))
+		})
+	})
+
+	context("when a profile is configured", func() {
+		var path string
+
+		it.Before(func() {
+			path = filepath.Join(workspace, "profiles", "merged.pgo")
+			Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(path, cpuProfile, 0600)).To(Succeed())
+		})
+
+		it("builds every target with it", func() {
+			profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+				Workspace: workspace,
+				Targets:   []string{"./cmd/api", "./cmd/worker"},
+				Profile:   path,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(profiles.Flag).To(Equal(path))
+			Expect(profiles.Targets).To(Equal(map[string]string{
+				"./cmd/api":    path,
+				"./cmd/worker": path,
+			}))
+			Expect(profiles.Digest).NotTo(BeEmpty())
+
+			Expect(bindingResolver.ResolveCall.CallCount).To(Equal(0))
+		})
+	})
+
+	context("when PGO is turned off", func() {
+		it("does not use any profile", func() {
+			profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+				Workspace: workspace,
+				Targets:   []string{"./cmd/api"},
+				Profile:   "off",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(profiles).To(Equal(gobuild.ProfileSet{
+				Flag:    "off",
+				Targets: map[string]string{},
+			}))
+		})
+	})
+
+	context("when a profile binding is provided", func() {
+		var bindingPath string
+
+		it.Before(func() {
+			bindingPath = t.TempDir()
+			Expect(os.WriteFile(filepath.Join(bindingPath, "default.pgo"), cpuProfile, 0600)).To(Succeed())
+
+			bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+				{
+					Name:    "profile",
+					Type:    "go-pgo-profile",
+					Path:    bindingPath,
+					Entries: map[string]*servicebindings.Entry{"default.pgo": servicebindings.NewEntry(filepath.Join(bindingPath, "default.pgo"))},
+				},
+			}
+		})
+
+		it("builds every target with the profile in the binding", func() {
+			profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+				Workspace: workspace,
+				Targets:   []string{"./cmd/api", "./cmd/worker"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(profiles.Flag).To(Equal(filepath.Join(bindingPath, "default.pgo")))
+			Expect(profiles.Targets).To(Equal(map[string]string{
+				"./cmd/api":    filepath.Join(bindingPath, "default.pgo"),
+				"./cmd/worker": filepath.Join(bindingPath, "default.pgo"),
+			}))
+		})
+	})
+
+	context("when the build flags set a profile", func() {
+		var path string
+
+		it.Before(func() {
+			path = filepath.Join(workspace, "profiles", "merged.pgo")
+			Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(path, cpuProfile, 0600)).To(Succeed())
+
+			bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+				{
+					Name:    "profile",
+					Type:    "go-pgo-profile",
+					Path:    "some-binding-path",
+					Entries: map[string]*servicebindings.Entry{"default.pgo": servicebindings.NewEntry(filepath.Join("some-binding-path", "default.pgo"))},
+				},
+			}
+		})
+
+		it("uses the profile of the flag, which the go command builds with", func() {
+			profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+				Workspace: workspace,
+				Targets:   []string{"./cmd/api", "./cmd/worker"},
+				Flags:     []string{"-trimpath", "-pgo", "profiles/merged.pgo"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(profiles.Targets).To(Equal(map[string]string{
+				"./cmd/api":    path,
+				"./cmd/worker": path,
+			}))
+		})
+
+		context("when the flags of a target set the profile", func() {
+			it("uses the profile of the flag for that target only", func() {
+				profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/api", "./cmd/worker"},
+					TargetConfiguration: map[string]gobuild.TargetConfiguration{
+						"./cmd/api":    {Flags: []string{"-pgo=auto"}},
+						"./cmd/worker": {Flags: []string{"-pgo=off"}},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(profiles.Targets).To(Equal(map[string]string{
+					"./cmd/api": filepath.Join(workspace, "cmd", "api", "default.pgo"),
+				}))
+			})
+		})
+
+		context("when a target lives in a nested module", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workspace, "cmd", "worker", "go.mod"), nil, 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workspace, "cmd", "worker", "profiles"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workspace, "cmd", "worker", "profiles", "merged.pgo"), cpuProfile, 0600)).To(Succeed())
+			})
+
+			it("resolves the profile of the flag against the module root, where the go command runs", func() {
+				profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/api", "./cmd/worker"},
+					Flags:     []string{"-pgo=profiles/merged.pgo"},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(profiles.Targets).To(Equal(map[string]string{
+					"./cmd/api":    path,
+					"./cmd/worker": filepath.Join(workspace, "cmd", "worker", "profiles", "merged.pgo"),
+				}))
+			})
+
+			context("when the workspace contains a go.work", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workspace, "go.work"), nil, 0600)).To(Succeed())
+				})
+
+				it("resolves the profile of the flag against the workspace", func() {
+					profiles, err := resolver.Resolve(gobuild.ProfileConfiguration{
+						Workspace: workspace,
+						Targets:   []string{"./cmd/worker"},
+						Flags:     []string{"-pgo=profiles/merged.pgo"},
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(profiles.Targets).To(Equal(map[string]string{
+						"./cmd/worker": path,
+					}))
+				})
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when a profile cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workspace, "cmd", "worker", "default.pgo"), []byte("not a profile"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/worker"},
+				})
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("PGO profile %s cannot be used:", filepath.Join(workspace, "cmd", "worker", "default.pgo")))))
+			})
+		})
+
+		context("when a profile does not contain CPU samples", func() {
+			it.Before(func() {
+				writeProfile(filepath.Join(workspace, "cmd", "worker", "default.pgo"), &profile.ValueType{Type: "alloc_space", Unit: "bytes"})
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/worker"},
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("PGO profile %s cannot be used: it does not contain CPU samples", filepath.Join(workspace, "cmd", "worker", "default.pgo"))))
+			})
+		})
+
+		context("when the configured profile does not exist", func() {
+			it("returns an error", func() {
+				_, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/api"},
+					Profile:   filepath.Join(workspace, "missing.pgo"),
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to read PGO profile:")))
+				Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+			})
+		})
+
+		context("when a profile is configured and the build flags set one too", func() {
+			it("returns an error", func() {
+				_, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/api", "./cmd/worker"},
+					TargetConfiguration: map[string]gobuild.TargetConfiguration{
+						"./cmd/worker": {Flags: []string{"-pgo=off"}},
+					},
+					Profile: filepath.Join(workspace, "cmd", "api", "default.pgo"),
+				})
+				Expect(err).To(MatchError("BP_GO_PGO cannot be combined with the -pgo build flag of target ./cmd/worker"))
+			})
+		})
+
+		context("when the profile of the build flags does not exist", func() {
+			it("returns an error", func() {
+				_, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/worker"},
+					Flags:     []string{"-pgo=missing.pgo"},
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to read PGO profile:")))
+				Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+			})
+		})
+
+		context("when the bindings cannot be resolved", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.Error = errors.New("failed to resolve")
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/api"},
+				})
+				Expect(err).To(MatchError("failed to resolve go-pgo-profile bindings: failed to resolve"))
+			})
+		})
+
+		context("when the binding does not contain a profile", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+					{Name: "profile", Type: "go-pgo-profile", Path: "some-path", Entries: map[string]*servicebindings.Entry{}},
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/api"},
+				})
+				Expect(err).To(MatchError(`go-pgo-profile binding "profile" must contain a "default.pgo" entry`))
+			})
+		})
+
+		context("when there is more than one profile binding", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+					{Name: "first", Type: "go-pgo-profile", Path: "first"},
+					{Name: "second", Type: "go-pgo-profile", Path: "second"},
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(gobuild.ProfileConfiguration{
+					Workspace: workspace,
+					Targets:   []string{"./cmd/api"},
+				})
+				Expect(err).To(MatchError("expected at most one go-pgo-profile binding, found 2"))
+			})
+		})
+	})
+}
@@ -3,81 +3,307 @@ package gobuild
 import (
 	"errors"
 	"fmt"
+	"go/build"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
 
-type GoTargetManager struct{}
+type GoTargetManager struct {
+	logs scribe.Emitter
+}
 
-func NewGoTargetManager() GoTargetManager {
-	return GoTargetManager{}
+func NewGoTargetManager(logs scribe.Emitter) GoTargetManager {
+	return GoTargetManager{
+		logs: logs,
+	}
 }
 
 func (tm GoTargetManager) CleanAndValidate(inputTargets []string, workingDir string) ([]string, error) {
 	var targets []string
+	seen := map[string]bool{}
+	add := func(target string) {
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+
 	for _, t := range inputTargets {
 		if strings.HasPrefix(t, string(filepath.Separator)) {
 			return nil, fmt.Errorf("failed to determine build targets: %q is an absolute path, targets must be relative to the source directory", t)
 		}
 
-		target := filepath.Clean(t)
+		if isTargetPattern(t) {
+			matches, err := expandTargetPattern(t, workingDir)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("failed to determine build targets: pattern %q did not match any main packages", t)
+			}
+
+			for _, match := range matches {
+				add(match)
+			}
+			continue
+		}
 
-		files, err := filepath.Glob(filepath.Join(workingDir, target, "*.go"))
+		files, err := filepath.Glob(filepath.Join(workingDir, filepath.Clean(t), "*.go"))
 		if err != nil {
 			return nil, err
 		}
 
 		if len(files) == 0 {
-			return nil, fmt.Errorf("there were no *.go files present in %q", filepath.Join(workingDir, target))
+			return nil, fmt.Errorf("there were no *.go files present in %q", filepath.Join(workingDir, filepath.Clean(t)))
 		}
 
-		targets = append(targets, fmt.Sprintf(".%c%s", filepath.Separator, target))
+		add(cleanTarget(t))
 	}
 
 	return targets, nil
 }
 
-func (tm GoTargetManager) GenerateDefaults(workingDir string) ([]string, error) {
-	files, err := filepath.Glob(filepath.Join(workingDir, "*.go"))
-	if err != nil {
-		return nil, err
+// cleanTarget gives every spelling of a target, such as cmd/server,
+// ./cmd/server and ./cmd/server/, the same form so that targets can be
+// compared with each other. The working directory itself is ".".
+func cleanTarget(target string) string {
+	target = filepath.ToSlash(filepath.Clean(target))
+	if target == "." {
+		return target
 	}
 
-	if len(files) > 0 {
-		return []string{"."}, nil
+	return "./" + target
+}
+
+// isTargetPattern reports whether the target is a Go package pattern, such as
+// ./cmd/..., rather than the path to a single package.
+func isTargetPattern(target string) bool {
+	return strings.Contains(target, "...")
+}
+
+// matchTargetPattern returns a function that reports whether a target matches
+// the given pattern. As with the go command, "..." matches any string and a
+// trailing "/..." also matches the directory it is appended to, so ./cmd/...
+// matches both ./cmd and ./cmd/server. A pattern without "..." only matches
+// the target itself.
+func matchTargetPattern(pattern string) func(target string) bool {
+	expr := regexp.QuoteMeta(filepath.ToSlash(filepath.Clean(pattern)))
+	expr = strings.ReplaceAll(expr, `\.\.\.`, `.*`)
+	if strings.HasSuffix(expr, `/.*`) {
+		expr = strings.TrimSuffix(expr, `/.*`) + `(/.*)?`
 	}
+	re := regexp.MustCompile(fmt.Sprintf("^%s$", expr))
+
+	return func(target string) bool {
+		return re.MatchString(filepath.ToSlash(filepath.Clean(target)))
+	}
+}
+
+// expandTargetPattern returns the main packages below the working directory
+// that match the given pattern. Like the go command, it does not descend into
+// vendor or testdata directories, directories beginning with "." or "_", or
+// nested modules.
+func expandTargetPattern(pattern, workingDir string) ([]string, error) {
+	match := matchTargetPattern(pattern)
+
+	root := filepath.Clean(pattern)
+	root = root[:strings.Index(root, "...")]
+	root = filepath.Join(workingDir, root[:strings.LastIndex(root, string(filepath.Separator))+1])
 
 	var targets []string
-	err = filepath.Walk(filepath.Join(workingDir, "cmd"), func(path string, info os.FileInfo, err error) error {
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if !info.IsDir() {
-			match, err := filepath.Match("*.go", info.Name())
+		if !entry.IsDir() {
+			return nil
+		}
+
+		if path != root {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return filepath.SkipDir
+			}
+
+			isModule, err := fs.Exists(filepath.Join(path, "go.mod"))
 			if err != nil {
 				return err
 			}
 
-			if match {
-				targets = append(targets, strings.ReplaceAll(filepath.Dir(path), workingDir, "."))
+			if isModule {
 				return filepath.SkipDir
 			}
 		}
 
+		rel, err := filepath.Rel(workingDir, path)
+		if err != nil {
+			return err
+		}
+
+		target := cleanTarget(rel)
+		if !match(target) {
+			return nil
+		}
+
+		pkg, err := build.ImportDir(path, 0)
+		if err != nil {
+			var noGoError *build.NoGoError
+			if errors.As(err, &noGoError) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to expand pattern %q: %w", pattern, err)
+		}
+
+		// Directories that only contain tests can not be built into a binary
+		if pkg.Name == "main" && len(pkg.GoFiles)+len(pkg.CgoFiles) > 0 {
+			targets = append(targets, target)
+		}
+
 		return nil
 	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
 
+	return targets, nil
+}
+
+// GenerateDefaults discovers the main packages within the working directory.
+// When the working directory is itself a main package it is the only target,
+// otherwise every main package up to the given depth below the working
+// directory is a target, with those in ./cmd listed first. Each directory that
+// is considered is logged along with the reason it was selected or skipped.
+func (tm GoTargetManager) GenerateDefaults(workingDir string, depth int) ([]string, error) {
+	tm.logs.Process("Discovering build targets")
+
+	isMain, reason, err := inspectPackage(workingDir)
 	if err != nil {
-		if !errors.Is(err, os.ErrNotExist) {
-			return nil, err
+		return nil, err
+	}
+
+	if isMain {
+		tm.logs.Subprocess("Selected .: %s", reason)
+		tm.logs.Break()
+		return []string{"."}, nil
+	}
+
+	if reason != "" {
+		tm.logs.Subprocess("Skipped .: %s", reason)
+	}
+
+	var commands, others []string
+	err = filepath.WalkDir(workingDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() || path == workingDir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(workingDir, path)
+		if err != nil {
+			return err
+		}
+		target := cleanTarget(rel)
+
+		name := entry.Name()
+		switch {
+		case strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_"):
+			tm.logs.Subprocess("Skipped %s: directories starting with %q are ignored", target, name[:1])
+			return filepath.SkipDir
+		case name == "testdata" || name == "vendor":
+			tm.logs.Subprocess("Skipped %s: %s directories are ignored", target, name)
+			return filepath.SkipDir
+		case strings.Count(rel, string(filepath.Separator))+1 > depth:
+			tm.logs.Subprocess("Skipped %s: deeper than the search depth of %d", target, depth)
+			return filepath.SkipDir
+		}
+
+		isModule, err := fs.Exists(filepath.Join(path, "go.mod"))
+		if err != nil {
+			return err
+		}
+
+		if isModule {
+			tm.logs.Subprocess("Skipped %s: nested module", target)
+			return filepath.SkipDir
+		}
+
+		isMain, reason, err := inspectPackage(path)
+		if err != nil {
+			return err
 		}
+
+		switch {
+		case isMain:
+			tm.logs.Subprocess("Selected %s: %s", target, reason)
+			if strings.HasPrefix(rel, fmt.Sprintf("cmd%c", filepath.Separator)) {
+				commands = append(commands, target)
+			} else {
+				others = append(others, target)
+			}
+		case reason != "":
+			tm.logs.Subprocess("Skipped %s: %s", target, reason)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	tm.logs.Break()
 
+	targets := append(commands, others...)
 	if len(targets) == 0 {
-		return nil, errors.New("no *.go files could be found")
+		return nil, fmt.Errorf("no main packages could be found within a depth of %d", depth)
 	}
 
 	return targets, nil
 }
+
+// inspectPackage reports whether the directory contains a main package that
+// can be built for the current platform, along with a human readable reason.
+// The reason is empty when the directory does not contain any Go files.
+func inspectPackage(dir string) (bool, string, error) {
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		var noGoError *build.NoGoError
+		if errors.As(err, &noGoError) {
+			if len(pkg.IgnoredGoFiles) > 0 {
+				return false, "no Go files match the build constraints", nil
+			}
+
+			return false, "", nil
+		}
+
+		var multiplePackageError *build.MultiplePackageError
+		if errors.As(err, &multiplePackageError) {
+			return false, fmt.Sprintf("contains multiple packages (%s)", strings.Join(multiplePackageError.Packages, ", ")), nil
+		}
+
+		if errors.Is(err, os.ErrPermission) {
+			return false, "", err
+		}
+
+		return false, fmt.Sprintf("package could not be read: %s", err), nil
+	}
+
+	if len(pkg.GoFiles)+len(pkg.CgoFiles) == 0 {
+		return false, "only contains test files", nil
+	}
+
+	if pkg.Name != "main" {
+		return false, fmt.Sprintf("package %s is not a main package", pkg.Name), nil
+	}
+
+	return true, "package main", nil
+}
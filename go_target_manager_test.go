@@ -1,15 +1,18 @@
 package gobuild_test
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
 	gobuild "github.com/paketo-buildpacks/go-build"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 	"github.com/sclevine/spec"
 
 	. "github.com/onsi/gomega"
+	. "github.com/paketo-buildpacks/occam/matchers"
 )
 
 func testGoTargetManager(t *testing.T, context spec.G, it spec.S) {
@@ -17,6 +20,7 @@ func testGoTargetManager(t *testing.T, context spec.G, it spec.S) {
 		Expect = NewWithT(t).Expect
 
 		workingDir string
+		logs       *bytes.Buffer
 
 		targetManager gobuild.GoTargetManager
 	)
@@ -26,8 +30,8 @@ func testGoTargetManager(t *testing.T, context spec.G, it spec.S) {
 		workingDir, err = os.MkdirTemp("", "working-dir")
 		Expect(err).NotTo(HaveOccurred())
 
-		targetManager = gobuild.NewGoTargetManager()
-
+		logs = bytes.NewBuffer(nil)
+		targetManager = gobuild.NewGoTargetManager(scribe.NewEmitter(logs))
 	})
 
 	it.After(func() {
@@ -54,6 +58,77 @@ func testGoTargetManager(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when the targets contain package patterns", func() {
+			it.Before(func() {
+				files := map[string]string{
+					"main.go":                           "package main",
+					"cmd/server/main.go":                "package main",
+					"cmd/worker/main.go":                "package main",
+					"cmd/worker/internal/queue.go":      "package queue",
+					"cmd/tools/gen/main.go":             "package main",
+					"cmd/tools/gen/testdata/main.go":    "package main",
+					"cmd/tools/only-tests/main_test.go": "package main",
+					"cmd/vendor/dep/main.go":            "package main",
+					"cmd/_scratch/main.go":              "package main",
+					"cmd/.hidden/main.go":               "package main",
+					"cmd/plugin/go.mod":                 "module example.com/plugin",
+					"cmd/plugin/main.go":                "package main",
+					"pkg/lib/lib.go":                    "package lib",
+				}
+
+				for path, content := range files {
+					Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0644)).To(Succeed())
+				}
+			})
+
+			it("expands the patterns to the main packages they match", func() {
+				targets, err := targetManager.CleanAndValidate([]string{"./cmd/server", "./cmd/..."}, workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(targets).To(Equal([]string{"./cmd/server", "./cmd/tools/gen", "./cmd/worker"}))
+			})
+
+			it("supports patterns matching the whole working directory", func() {
+				targets, err := targetManager.CleanAndValidate([]string{"./..."}, workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(targets).To(Equal([]string{".", "./cmd/server", "./cmd/tools/gen", "./cmd/worker"}))
+			})
+
+			it("removes targets that are given more than once in different forms", func() {
+				targets, err := targetManager.CleanAndValidate([]string{".", "./.", "cmd/server", "./cmd/server/", "./..."}, workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(targets).To(Equal([]string{".", "./cmd/server", "./cmd/tools/gen", "./cmd/worker"}))
+			})
+
+			it("supports wildcards within a path", func() {
+				targets, err := targetManager.CleanAndValidate([]string{"cmd/.../gen"}, workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(targets).To(Equal([]string{"./cmd/tools/gen"}))
+			})
+
+			context("when a pattern does not match any main packages", func() {
+				it("returns an error", func() {
+					_, err := targetManager.CleanAndValidate([]string{"./pkg/..."}, workingDir)
+					Expect(err).To(MatchError(`failed to determine build targets: pattern "./pkg/..." did not match any main packages`))
+				})
+			})
+
+			context("when a directory matching a pattern contains more than one package", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "cmd", "server", "other.go"), []byte("package other"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := targetManager.CleanAndValidate([]string{"./cmd/..."}, workingDir)
+					Expect(err).To(MatchError(ContainSubstring(`failed to expand pattern "./cmd/...": found packages main (main.go) and other (other.go)`)))
+				})
+			})
+		})
+
 		context("when one of the targets in an absolute path", func() {
 			it("returns an error", func() {
 				_, err := targetManager.CleanAndValidate([]string{"/first"}, workingDir)
@@ -87,54 +162,118 @@ func testGoTargetManager(t *testing.T, context spec.G, it spec.S) {
 	})
 
 	context("GenerateDefaults", func() {
-		context("when there is a *.go file in the workingDir", func() {
+		context("when the workingDir is a main package", func() {
 			it.Before(func() {
-				Expect(os.WriteFile(filepath.Join(workingDir, "main.go"), nil, 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "main.go"), []byte("package main"), 0644)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(workingDir, "cmd", "first"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "cmd", "first", "main.go"), []byte("package main"), 0644)).To(Succeed())
 			})
 
-			it("returns . as the target", func() {
-				targets, err := targetManager.GenerateDefaults(workingDir)
+			it("returns . as the only target", func() {
+				targets, err := targetManager.GenerateDefaults(workingDir, 3)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(targets).To(Equal([]string{"."}))
+
+				Expect(logs).To(ContainLines(
+					"  Discovering build targets",
+					"    Selected .: package main",
+				))
 			})
 		})
 
-		context("when there are go files nested inside of a ./cmd folder", func() {
+		context("when there are main packages nested inside of the workingDir", func() {
 			it.Before(func() {
-				targetDir := filepath.Join(workingDir, "cmd", "first")
-				Expect(os.MkdirAll(targetDir, os.ModePerm)).To(Succeed())
-				Expect(os.WriteFile(filepath.Join(targetDir, "main.go"), nil, 0644)).To(Succeed())
-
-				targetDir = filepath.Join(workingDir, "cmd", "something", "second")
-				Expect(os.MkdirAll(targetDir, os.ModePerm)).To(Succeed())
-				Expect(os.WriteFile(filepath.Join(targetDir, "main.go"), nil, 0644)).To(Succeed())
+				files := map[string]string{
+					"lib.go":                         "package app",
+					"cmd/first/main.go":              "package main",
+					"cmd/something/second/main.go":   "package main",
+					"cmd/something/second/helper.go": "package main",
+					"cmd/library/lib.go":             "package library",
+					"cmd/tests/main_test.go":         "package main",
+					"cmd/windows/main.go":            "//go:build windows\n\npackage main",
+					"cmd/multiple/main.go":           "package main",
+					"cmd/multiple/other.go":          "package other",
+					"cmd/plugin/go.mod":              "module example.com/plugin",
+					"cmd/plugin/main.go":             "package main",
+					"cmd/a/b/c/main.go":              "package main",
+					"examples/hello/main.go":         "package main",
+					"internal/tool/main.go":          "package main",
+					"vendor/example.com/dep/main.go": "package main",
+					"testdata/fixture/main.go":       "package main",
+					".hidden/main.go":                "package main",
+					"_scratch/main.go":               "package main",
+					"docs/README.md":                 "",
+				}
+
+				for path, content := range files {
+					Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0644)).To(Succeed())
+				}
 			})
 
-			it("returns a target list of all top level directories in ./cmd that contain *.go files", func() {
-				targets, err := targetManager.GenerateDefaults(workingDir)
+			it("returns the main packages with those in ./cmd first", func() {
+				targets, err := targetManager.GenerateDefaults(workingDir, 3)
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(targets).To(Equal([]string{"./cmd/first", "./cmd/something/second"}))
+				Expect(targets).To(Equal([]string{
+					"./cmd/first",
+					"./cmd/something/second",
+					"./examples/hello",
+					"./internal/tool",
+				}))
+
+				Expect(logs).To(ContainLines(
+					"  Discovering build targets",
+					"    Skipped .: package app is not a main package",
+					`    Skipped ./.hidden: directories starting with "." are ignored`,
+					`    Skipped ./_scratch: directories starting with "_" are ignored`,
+					"    Skipped ./cmd/a/b/c: deeper than the search depth of 3",
+					"    Selected ./cmd/first: package main",
+					"    Skipped ./cmd/library: package library is not a main package",
+					"    Skipped ./cmd/multiple: contains multiple packages (main, other)",
+					"    Skipped ./cmd/plugin: nested module",
+					"    Selected ./cmd/something/second: package main",
+					"    Skipped ./cmd/tests: only contains test files",
+					"    Skipped ./cmd/windows: no Go files match the build constraints",
+					"    Selected ./examples/hello: package main",
+					"    Selected ./internal/tool: package main",
+					"    Skipped ./testdata: testdata directories are ignored",
+					"    Skipped ./vendor: vendor directories are ignored",
+				))
+				Expect(logs.String()).NotTo(ContainSubstring("./docs"))
 			})
-		})
 
-		context("when there is no *.go in the app root in ./cmd", func() {
-			it("returns a target list of all top level directories in ./cmd that contain *.go files", func() {
-				_, err := targetManager.GenerateDefaults(workingDir)
-				Expect(err).To(MatchError(ContainSubstring("no *.go files could be found")))
+			context("when the depth is limited", func() {
+				it("only searches the directories up to that depth", func() {
+					targets, err := targetManager.GenerateDefaults(workingDir, 2)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(targets).To(Equal([]string{
+						"./cmd/first",
+						"./examples/hello",
+						"./internal/tool",
+					}))
+
+					Expect(logs).To(ContainLines("    Skipped ./cmd/something/second: deeper than the search depth of 2"))
+				})
 			})
 		})
 
-		context("failure cases", func() {
-			context("when file glob failes", func() {
-				it("returns an error", func() {
-					_, err := targetManager.GenerateDefaults(`\`)
-					Expect(err).To(MatchError(ContainSubstring("syntax error in pattern")))
+		context("when there are no main packages in the workingDir", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "cmd", "first"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "cmd", "first", "lib.go"), []byte("package first"), 0644)).To(Succeed())
+			})
 
-				})
+			it("returns an error", func() {
+				_, err := targetManager.GenerateDefaults(workingDir, 3)
+				Expect(err).To(MatchError("no main packages could be found within a depth of 3"))
 			})
+		})
 
+		context("failure cases", func() {
 			context("when the workingDir is unstatable", func() {
 				it.Before(func() {
 					Expect(os.Chmod(workingDir, 0000)).To(Succeed())
@@ -145,7 +284,7 @@ func testGoTargetManager(t *testing.T, context spec.G, it spec.S) {
 				})
 
 				it("returns an error", func() {
-					_, err := targetManager.GenerateDefaults(workingDir)
+					_, err := targetManager.GenerateDefaults(workingDir, 3)
 					Expect(err).To(MatchError(ContainSubstring("permission denied")))
 				})
 			})
@@ -0,0 +1,34 @@
+package gobuild
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+type GoToolchain struct {
+	executable Executable
+}
+
+func NewGoToolchain(executable Executable) GoToolchain {
+	return GoToolchain{
+		executable: executable,
+	}
+}
+
+func (t GoToolchain) Version() (string, error) {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	err := t.executable.Execute(pexec.Execution{
+		Args:   []string{"env", "GOVERSION"},
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute 'go env GOVERSION': %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,62 @@
+package gobuild_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	gobuild "github.com/paketo-buildpacks/go-build"
+	"github.com/paketo-buildpacks/go-build/fakes"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testGoToolchain(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		executable *fakes.Executable
+
+		toolchain gobuild.GoToolchain
+	)
+
+	it.Before(func() {
+		executable = &fakes.Executable{}
+		executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+			_, err := fmt.Fprintln(execution.Stdout, "go1.22.4")
+			Expect(err).NotTo(HaveOccurred())
+			return nil
+		}
+
+		toolchain = gobuild.NewGoToolchain(executable)
+	})
+
+	context("Version", func() {
+		it("returns the version of the go toolchain", func() {
+			version, err := toolchain.Version()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("go1.22.4"))
+
+			Expect(executable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"env", "GOVERSION"}))
+		})
+
+		context("failure cases", func() {
+			context("when the executable fails", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						_, err := fmt.Fprintln(execution.Stderr, "go: command not found")
+						Expect(err).NotTo(HaveOccurred())
+						return errors.New("command failed")
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := toolchain.Version()
+					Expect(err).To(MatchError("failed to execute 'go env GOVERSION': command failed: go: command not found"))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,413 @@
+package gobuild
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// GoVulnerabilityDatabaseBindingType is the type of service bindings that
+	// provide a Go vulnerability database for vulnerability scanning. The
+	// database is a "db" directory in the layout served by vuln.go.dev.
+	GoVulnerabilityDatabaseBindingType = "go-vulnerability-database"
+
+	// VulnerabilityReportFileName is the name of the file in the targets layer
+	// that lists the vulnerabilities found in the binaries.
+	VulnerabilityReportFileName = "vulnerabilities.json"
+
+	unknownSeverity = "UNKNOWN"
+)
+
+//go:generate faux --interface VulnerabilityScanner --output fakes/vulnerability_scanner.go
+type VulnerabilityScanner interface {
+	DatabaseVersion(config VulnerabilityScanConfiguration) (string, error)
+	Scan(config VulnerabilityScanConfiguration) error
+}
+
+type VulnerabilityScanConfiguration struct {
+	Binaries        []string
+	DebugSymbols    map[string]string
+	PlatformPath    string
+	Database        string
+	Allow           []string
+	AllowSeverities []string
+	Report          string
+}
+
+// VulnerabilityFinding is a vulnerable symbol that is compiled into a binary.
+type VulnerabilityFinding struct {
+	ID           string   `json:"id"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Summary      string   `json:"summary,omitempty"`
+	Severity     string   `json:"severity"`
+	Binary       string   `json:"binary"`
+	Module       string   `json:"module"`
+	Version      string   `json:"version,omitempty"`
+	FixedVersion string   `json:"fixed_version,omitempty"`
+	Symbol       string   `json:"symbol"`
+	Allowed      bool     `json:"allowed"`
+}
+
+type GoVulnerabilityScanner struct {
+	executable Executable
+	logs       scribe.Emitter
+	clock      chronos.Clock
+	bindings   BindingResolver
+}
+
+func NewGoVulnerabilityScanner(executable Executable, logs scribe.Emitter, clock chronos.Clock, bindings BindingResolver) GoVulnerabilityScanner {
+	return GoVulnerabilityScanner{
+		executable: executable,
+		logs:       logs,
+		clock:      clock,
+		bindings:   bindings,
+	}
+}
+
+// DatabaseVersion returns the time at which the vulnerability database was
+// last modified, as recorded in its index. The binaries are only scanned when
+// they are rebuilt, so the version is part of the fingerprint of the build to
+// rescan them whenever the database is updated.
+func (s GoVulnerabilityScanner) DatabaseVersion(config VulnerabilityScanConfiguration) (string, error) {
+	database, err := s.resolveDatabase(config)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(filepath.Join(database, "index", "db.json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read vulnerability database index: %w", err)
+	}
+
+	var index struct {
+		Modified string `json:"modified"`
+	}
+	err = json.Unmarshal(content, &index)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse vulnerability database index: %w", err)
+	}
+
+	if index.Modified == "" {
+		return "", fmt.Errorf("vulnerability database index %s does not record when it was modified", filepath.Join(database, "index", "db.json"))
+	}
+
+	return index.Modified, nil
+}
+
+// Scan checks the binaries against the vulnerability database with
+// govulncheck. Binaries only contain the functions that the linker found to
+// be reachable, so only vulnerable symbols that can actually be called are
+// reported. Binaries that have an unstripped copy in DebugSymbols are scanned
+// through that copy. The findings are written to the report and the scan
+// fails when any of them are not allowed by the policy.
+func (s GoVulnerabilityScanner) Scan(config VulnerabilityScanConfiguration) error {
+	s.logs.Process("Scanning for vulnerabilities")
+
+	database, err := s.resolveDatabase(config)
+	if err != nil {
+		return err
+	}
+
+	databaseURL := (&url.URL{Scheme: "file", Path: database}).String()
+	s.logs.Subprocess("Using the vulnerability database %s", database)
+
+	var findings []VulnerabilityFinding
+	for _, binary := range config.Binaries {
+		// Without a symbol table govulncheck cannot tell which functions are
+		// compiled into the binary, and would report vulnerable symbols that
+		// cannot be called
+		scanned := binary
+		if symbols, ok := config.DebugSymbols[binary]; ok {
+			scanned = symbols
+		}
+
+		args := []string{"-mode=binary", "-db", databaseURL, "-format", "json", scanned}
+		s.logs.Subprocess("Running 'govulncheck %s'", strings.Join(args, " "))
+
+		stdout := bytes.NewBuffer(nil)
+		duration, err := s.clock.Measure(func() error {
+			return s.executable.Execute(pexec.Execution{
+				Args:   args,
+				Env:    os.Environ(),
+				Stdout: stdout,
+				Stderr: s.logs.ActionWriter,
+			})
+		})
+		if err != nil {
+			s.logs.Action("Failed after %s", duration.Round(time.Millisecond))
+			return fmt.Errorf("failed to execute 'govulncheck': %w", err)
+		}
+
+		s.logs.Action("Completed in %s", duration.Round(time.Millisecond))
+
+		binaryFindings, err := parseGovulncheckOutput(stdout, binary)
+		if err != nil {
+			return err
+		}
+
+		for i, finding := range binaryFindings {
+			binaryFindings[i].Severity, err = readSeverity(database, finding.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		findings = append(findings, binaryFindings...)
+	}
+
+	var violations []string
+	for i, finding := range findings {
+		findings[i].Allowed = isAllowedFinding(finding, config.Allow, config.AllowSeverities)
+		if !findings[i].Allowed {
+			violations = append(violations, finding.String())
+		}
+	}
+
+	err = writeVulnerabilityReport(config.Report, databaseURL, findings)
+	if err != nil {
+		return err
+	}
+
+	s.logs.Break()
+	if len(findings) == 0 {
+		s.logs.Subprocess("No reachable vulnerabilities found")
+	} else {
+		s.logs.Subprocess("Found %d reachable vulnerable symbols, %d allowed by the policy", len(findings), len(findings)-len(violations))
+		for _, finding := range findings {
+			if finding.Allowed {
+				s.logs.Action("%s (allowed)", finding)
+				continue
+			}
+			s.logs.Action("%s", finding)
+		}
+	}
+	s.logs.Break()
+
+	if len(violations) > 0 {
+		return packit.Fail.WithMessage("vulnerability policy violated by the following findings:\n  %s", strings.Join(violations, "\n  "))
+	}
+
+	return nil
+}
+
+func (f VulnerabilityFinding) String() string {
+	description := fmt.Sprintf("%s (%s) in %s", f.ID, f.Severity, f.Module)
+	if f.Version != "" {
+		description += "@" + f.Version
+	}
+	description += fmt.Sprintf(": %s in %s", f.Symbol, filepath.Base(f.Binary))
+
+	if f.FixedVersion != "" {
+		description += fmt.Sprintf(", fixed in %s", f.FixedVersion)
+	}
+
+	return description
+}
+
+// resolveDatabase returns the database directory given by the configuration
+// or by a vulnerability database binding.
+func (s GoVulnerabilityScanner) resolveDatabase(config VulnerabilityScanConfiguration) (string, error) {
+	database := config.Database
+	if database == "" {
+		bindings, err := s.bindings.Resolve(GoVulnerabilityDatabaseBindingType, "", config.PlatformPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s bindings: %w", GoVulnerabilityDatabaseBindingType, err)
+		}
+
+		switch len(bindings) {
+		case 0:
+			return "", packit.Fail.WithMessage("vulnerability scanning requires a vulnerability database, set BP_GO_VULNCHECK_DB or provide a %s binding", GoVulnerabilityDatabaseBindingType)
+		case 1:
+			database = filepath.Join(bindings[0].Path, "db")
+		default:
+			return "", fmt.Errorf("expected at most one %s binding, found %d", GoVulnerabilityDatabaseBindingType, len(bindings))
+		}
+	}
+
+	info, err := os.Stat(database)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to check for vulnerability database: %w", err)
+	}
+
+	if info == nil || !info.IsDir() {
+		return "", fmt.Errorf("vulnerability database %s is not a directory", database)
+	}
+
+	return database, nil
+}
+
+// govulncheckMessage is an entry of the JSON stream written by govulncheck.
+// Only the parts that are needed for the report are decoded.
+type govulncheckMessage struct {
+	OSV *struct {
+		ID      string   `json:"id"`
+		Aliases []string `json:"aliases"`
+		Summary string   `json:"summary"`
+	} `json:"osv"`
+	Finding *struct {
+		OSV          string `json:"osv"`
+		FixedVersion string `json:"fixed_version"`
+		Trace        []struct {
+			Module   string `json:"module"`
+			Version  string `json:"version"`
+			Package  string `json:"package"`
+			Function string `json:"function"`
+			Receiver string `json:"receiver"`
+		} `json:"trace"`
+	} `json:"finding"`
+}
+
+// parseGovulncheckOutput returns the symbol level findings in the output of
+// govulncheck. Findings at the module and package level only mean that a
+// vulnerable module is required, not that the vulnerable code is compiled in.
+func parseGovulncheckOutput(output io.Reader, binary string) ([]VulnerabilityFinding, error) {
+	type osvEntry struct {
+		aliases []string
+		summary string
+	}
+
+	entries := map[string]osvEntry{}
+	var findings []VulnerabilityFinding
+	seen := map[string]bool{}
+
+	decoder := json.NewDecoder(output)
+	for {
+		var message govulncheckMessage
+		err := decoder.Decode(&message)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("failed to parse govulncheck output: %w", err)
+		}
+
+		if message.OSV != nil {
+			entries[message.OSV.ID] = osvEntry{
+				aliases: message.OSV.Aliases,
+				summary: message.OSV.Summary,
+			}
+		}
+
+		if message.Finding == nil || len(message.Finding.Trace) == 0 || message.Finding.Trace[0].Function == "" {
+			continue
+		}
+
+		frame := message.Finding.Trace[0]
+		symbol := frame.Function
+		if frame.Receiver != "" {
+			symbol = fmt.Sprintf("%s.%s", frame.Receiver, frame.Function)
+		}
+		symbol = fmt.Sprintf("%s.%s", frame.Package, symbol)
+
+		key := message.Finding.OSV + "\x00" + symbol
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		findings = append(findings, VulnerabilityFinding{
+			ID:           message.Finding.OSV,
+			Binary:       binary,
+			Module:       frame.Module,
+			Version:      frame.Version,
+			FixedVersion: message.Finding.FixedVersion,
+			Symbol:       symbol,
+		})
+	}
+
+	// The entries are written before the findings that refer to them, but
+	// nothing in the format guarantees it
+	for i, finding := range findings {
+		entry := entries[finding.ID]
+		findings[i].Aliases = entry.aliases
+		findings[i].Summary = entry.summary
+	}
+
+	return findings, nil
+}
+
+// readSeverity returns the severity of a vulnerability from its entry in the
+// database. govulncheck leaves out the database specific fields of the
+// entries, where databases such as those of GitHub record the severity.
+func readSeverity(database, id string) (string, error) {
+	var entry struct {
+		DatabaseSpecific struct {
+			Severity string `json:"severity"`
+		} `json:"database_specific"`
+	}
+
+	content, err := os.ReadFile(filepath.Join(database, "ID", id+".json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return unknownSeverity, nil
+		}
+
+		return "", fmt.Errorf("failed to read vulnerability %s: %w", id, err)
+	}
+
+	err = json.Unmarshal(content, &entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse vulnerability %s: %w", id, err)
+	}
+
+	if entry.DatabaseSpecific.Severity == "" {
+		return unknownSeverity, nil
+	}
+
+	return strings.ToUpper(entry.DatabaseSpecific.Severity), nil
+}
+
+// isAllowedFinding reports whether the finding is allowed by its ID, any of
+// its aliases, or its severity.
+func isAllowedFinding(finding VulnerabilityFinding, allow, allowSeverities []string) bool {
+	for _, id := range allow {
+		if strings.EqualFold(id, finding.ID) || slices.ContainsFunc(finding.Aliases, func(alias string) bool {
+			return strings.EqualFold(id, alias)
+		}) {
+			return true
+		}
+	}
+
+	return slices.ContainsFunc(allowSeverities, func(severity string) bool {
+		return strings.EqualFold(severity, finding.Severity)
+	})
+}
+
+func writeVulnerabilityReport(path, database string, findings []VulnerabilityFinding) error {
+	if findings == nil {
+		findings = []VulnerabilityFinding{}
+	}
+
+	content, err := json.MarshalIndent(struct {
+		Database string                 `json:"database"`
+		Findings []VulnerabilityFinding `json:"findings"`
+	}{
+		Database: database,
+		Findings: findings,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode vulnerability report: %w", err)
+	}
+
+	err = os.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write vulnerability report: %w", err)
+	}
+
+	return nil
+}
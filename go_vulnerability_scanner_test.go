@@ -0,0 +1,506 @@
+package gobuild_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gobuild "github.com/paketo-buildpacks/go-build"
+	"github.com/paketo-buildpacks/go-build/fakes"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+// govulncheckOutput is the JSON stream of a govulncheck scan that finds a
+// vulnerable module and a vulnerable symbol that is compiled into the binary.
+const govulncheckOutput = `{"config":{"protocol_version":"v1.0.0","scanner_name":"govulncheck","scan_level":"symbol"}}
+{"osv":{"id":"GO-2024-0001","aliases":["CVE-2024-0001"],"summary":"Denial of service in some-module"}}
+{"osv":{"id":"GO-2024-0002","summary":"Path traversal in other-module"}}
+{"finding":{"osv":"GO-2024-0001","fixed_version":"v1.2.4","trace":[{"module":"example.com/some-module","version":"v1.2.3"}]}}
+{"finding":{"osv":"GO-2024-0002","trace":[{"module":"example.com/other-module","version":"v0.1.0"}]}}
+{"finding":{"osv":"GO-2024-0001","fixed_version":"v1.2.4","trace":[{"module":"example.com/some-module","version":"v1.2.3","package":"example.com/some-module/parse","function":"Parse","receiver":"*Parser"}]}}
+{"finding":{"osv":"GO-2024-0001","fixed_version":"v1.2.4","trace":[{"module":"example.com/some-module","version":"v1.2.3","package":"example.com/some-module/parse","function":"Parse","receiver":"*Parser"},{"module":"example.com/app","package":"main","function":"main"}]}}
+`
+
+func testGoVulnerabilityScanner(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		layerPath  string
+		database   string
+		executions []pexec.Execution
+
+		executable      *fakes.Executable
+		bindingResolver *fakes.BindingResolver
+		logs            *bytes.Buffer
+
+		scanner gobuild.GoVulnerabilityScanner
+	)
+
+	type report struct {
+		Database string                         `json:"database"`
+		Findings []gobuild.VulnerabilityFinding `json:"findings"`
+	}
+
+	readReport := func() report {
+		content, err := os.ReadFile(filepath.Join(layerPath, "vulnerabilities.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var r report
+		Expect(json.Unmarshal(content, &r)).To(Succeed())
+
+		return r
+	}
+
+	it.Before(func() {
+		var err error
+		layerPath, err = os.MkdirTemp("", "layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		database, err = os.MkdirTemp("", "vulndb")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(database, "ID"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(database, "ID", "GO-2024-0001.json"), []byte(`{
+			"id": "GO-2024-0001",
+			"database_specific": {"severity": "high"}
+		}`), 0600)).To(Succeed())
+
+		logs = bytes.NewBuffer(nil)
+
+		executions = nil
+		executable = &fakes.Executable{}
+		executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+			executions = append(executions, execution)
+
+			if filepath.Base(execution.Args[len(execution.Args)-1]) == "some-binary" {
+				_, err := fmt.Fprint(execution.Stdout, govulncheckOutput)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			return nil
+		}
+
+		now := time.Now()
+		times := []time.Time{now, now.Add(1 * time.Second)}
+
+		clock := chronos.NewClock(func() time.Time {
+			if len(times) == 0 {
+				return time.Now()
+			}
+
+			t := times[0]
+			times = times[1:]
+			return t
+		})
+
+		bindingResolver = &fakes.BindingResolver{}
+
+		scanner = gobuild.NewGoVulnerabilityScanner(executable, scribe.NewEmitter(logs), clock, bindingResolver)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(layerPath)).To(Succeed())
+		Expect(os.RemoveAll(database)).To(Succeed())
+	})
+
+	it("reports the reachable vulnerable symbols and fails when they are not allowed", func() {
+		err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+			Binaries: []string{"bin/some-binary", "bin/other-binary"},
+			Database: database,
+			Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+		})
+		Expect(err).To(MatchError(fmt.Sprintf("vulnerability policy violated by the following findings:\n  %s",
+			"GO-2024-0001 (HIGH) in example.com/some-module@v1.2.3: example.com/some-module/parse.*Parser.Parse in some-binary, fixed in v1.2.4")))
+
+		Expect(executions).To(HaveLen(2))
+		Expect(executions[0].Args).To(Equal([]string{"-mode=binary", "-db", "file://" + database, "-format", "json", "bin/some-binary"}))
+		Expect(executions[1].Args).To(Equal([]string{"-mode=binary", "-db", "file://" + database, "-format", "json", "bin/other-binary"}))
+		Expect(bindingResolver.ResolveCall.CallCount).To(Equal(0))
+
+		Expect(readReport()).To(Equal(report{
+			Database: "file://" + database,
+			Findings: []gobuild.VulnerabilityFinding{
+				{
+					ID:           "GO-2024-0001",
+					Aliases:      []string{"CVE-2024-0001"},
+					Summary:      "Denial of service in some-module",
+					Severity:     "HIGH",
+					Binary:       "bin/some-binary",
+					Module:       "example.com/some-module",
+					Version:      "v1.2.3",
+					FixedVersion: "v1.2.4",
+					Symbol:       "example.com/some-module/parse.*Parser.Parse",
+				},
+			},
+		}))
+
+		Expect(logs.String()).To(ContainSubstring("Scanning for vulnerabilities"))
+		Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("Using the vulnerability database %s", database)))
+		Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("Running 'govulncheck -mode=binary -db file://%s -format json bin/some-binary'", database)))
+		Expect(logs.String()).To(ContainSubstring("Found 1 reachable vulnerable symbols, 0 allowed by the policy"))
+		Expect(logs.String()).NotTo(ContainSubstring("GO-2024-0002"))
+	})
+
+	context("when the finding is allowed by its ID", func() {
+		it("reports the finding as allowed", func() {
+			err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+				Binaries: []string{"bin/some-binary"},
+				Database: database,
+				Allow:    []string{"go-2024-0001"},
+				Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			findings := readReport().Findings
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0].Allowed).To(BeTrue())
+
+			Expect(logs.String()).To(ContainSubstring("Found 1 reachable vulnerable symbols, 1 allowed by the policy"))
+			Expect(logs.String()).To(ContainSubstring("in some-binary, fixed in v1.2.4 (allowed)"))
+		})
+	})
+
+	context("when the finding is allowed by one of its aliases", func() {
+		it("reports the finding as allowed", func() {
+			err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+				Binaries: []string{"bin/some-binary"},
+				Database: database,
+				Allow:    []string{"CVE-2024-0001"},
+				Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(readReport().Findings[0].Allowed).To(BeTrue())
+		})
+	})
+
+	context("when the finding is allowed by its severity", func() {
+		it("reports the finding as allowed", func() {
+			err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+				Binaries:        []string{"bin/some-binary"},
+				Database:        database,
+				AllowSeverities: []string{"moderate", "high"},
+				Report:          filepath.Join(layerPath, "vulnerabilities.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(readReport().Findings[0].Allowed).To(BeTrue())
+		})
+	})
+
+	context("when the database does not record the severity", func() {
+		it.Before(func() {
+			Expect(os.Remove(filepath.Join(database, "ID", "GO-2024-0001.json"))).To(Succeed())
+		})
+
+		it("reports the severity as unknown", func() {
+			err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+				Binaries:        []string{"bin/some-binary"},
+				Database:        database,
+				AllowSeverities: []string{"UNKNOWN"},
+				Report:          filepath.Join(layerPath, "vulnerabilities.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(readReport().Findings[0].Severity).To(Equal("UNKNOWN"))
+		})
+	})
+
+	context("when no vulnerable symbols are reachable", func() {
+		it("writes an empty report", func() {
+			err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+				Binaries: []string{"bin/other-binary"},
+				Database: database,
+				Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := os.ReadFile(filepath.Join(layerPath, "vulnerabilities.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(fmt.Sprintf(`{"database": "file://%s", "findings": []}`, database)))
+
+			Expect(logs.String()).To(ContainSubstring("No reachable vulnerabilities found"))
+		})
+	})
+
+	context("when the debug symbols of a binary have been split from it", func() {
+		it.Before(func() {
+			executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+				executions = append(executions, execution)
+
+				if execution.Args[len(execution.Args)-1] == "debug/.build-id/ab/cdef.debug" {
+					_, err := fmt.Fprint(execution.Stdout, govulncheckOutput)
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				return nil
+			}
+		})
+
+		it("scans the unstripped copy and reports the binary", func() {
+			err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+				Binaries:     []string{"bin/some-binary", "tests/store.test"},
+				DebugSymbols: map[string]string{"bin/some-binary": "debug/.build-id/ab/cdef.debug"},
+				Database:     database,
+				Allow:        []string{"GO-2024-0001"},
+				Report:       filepath.Join(layerPath, "vulnerabilities.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(executions).To(HaveLen(2))
+			Expect(executions[0].Args).To(Equal([]string{"-mode=binary", "-db", "file://" + database, "-format", "json", "debug/.build-id/ab/cdef.debug"}))
+			Expect(executions[1].Args).To(Equal([]string{"-mode=binary", "-db", "file://" + database, "-format", "json", "tests/store.test"}))
+
+			findings := readReport().Findings
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0].Binary).To(Equal("bin/some-binary"))
+		})
+	})
+
+	context("when the database is provided by a binding", func() {
+		var bindingPath string
+
+		it.Before(func() {
+			var err error
+			bindingPath, err = os.MkdirTemp("", "binding")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.Rename(database, filepath.Join(bindingPath, "db"))).To(Succeed())
+
+			bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+				{Name: "vulndb", Type: "go-vulnerability-database", Path: bindingPath},
+			}
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(bindingPath)).To(Succeed())
+		})
+
+		it("uses the database in the binding", func() {
+			err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+				Binaries:     []string{"bin/other-binary"},
+				PlatformPath: "some-platform-path",
+				Report:       filepath.Join(layerPath, "vulnerabilities.json"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(bindingResolver.ResolveCall.Receives.Typ).To(Equal("go-vulnerability-database"))
+			Expect(bindingResolver.ResolveCall.Receives.Provider).To(BeEmpty())
+			Expect(bindingResolver.ResolveCall.Receives.PlatformDir).To(Equal("some-platform-path"))
+
+			Expect(executions[0].Args).To(ContainElement("file://" + filepath.Join(bindingPath, "db")))
+		})
+	})
+
+	context("when the version of the database is requested", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(database, "index"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(database, "index", "db.json"), []byte(`{"modified":"2024-05-01T12:00:00Z"}`), 0600)).To(Succeed())
+		})
+
+		it("returns when the database was last modified", func() {
+			version, err := scanner.DatabaseVersion(gobuild.VulnerabilityScanConfiguration{
+				Database: database,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("2024-05-01T12:00:00Z"))
+			Expect(executions).To(BeEmpty())
+		})
+
+		context("when the database is provided by a binding", func() {
+			var bindingPath string
+
+			it.Before(func() {
+				var err error
+				bindingPath, err = os.MkdirTemp("", "binding")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(os.Rename(database, filepath.Join(bindingPath, "db"))).To(Succeed())
+
+				bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+					{Name: "vulndb", Type: "go-vulnerability-database", Path: bindingPath},
+				}
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(bindingPath)).To(Succeed())
+			})
+
+			it("returns the version of the database in the binding", func() {
+				version, err := scanner.DatabaseVersion(gobuild.VulnerabilityScanConfiguration{
+					PlatformPath: "some-platform-path",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(Equal("2024-05-01T12:00:00Z"))
+
+				Expect(bindingResolver.ResolveCall.Receives.PlatformDir).To(Equal("some-platform-path"))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when no database is provided", func() {
+			it("returns an error", func() {
+				err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+					Binaries: []string{"bin/some-binary"},
+					Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+				})
+				Expect(err).To(MatchError("vulnerability scanning requires a vulnerability database, set BP_GO_VULNCHECK_DB or provide a go-vulnerability-database binding"))
+				Expect(executions).To(BeEmpty())
+			})
+		})
+
+		context("when the bindings cannot be resolved", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.Error = errors.New("failed to resolve")
+			})
+
+			it("returns an error", func() {
+				err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+					Binaries: []string{"bin/some-binary"},
+					Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+				})
+				Expect(err).To(MatchError("failed to resolve go-vulnerability-database bindings: failed to resolve"))
+			})
+		})
+
+		context("when there is more than one database binding", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+					{Name: "first", Type: "go-vulnerability-database", Path: "first"},
+					{Name: "second", Type: "go-vulnerability-database", Path: "second"},
+				}
+			})
+
+			it("returns an error", func() {
+				err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+					Binaries: []string{"bin/some-binary"},
+					Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+				})
+				Expect(err).To(MatchError("expected at most one go-vulnerability-database binding, found 2"))
+			})
+		})
+
+		context("when the database is not a directory", func() {
+			it("returns an error", func() {
+				err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+					Binaries: []string{"bin/some-binary"},
+					Database: filepath.Join(database, "missing"),
+					Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("vulnerability database %s is not a directory", filepath.Join(database, "missing"))))
+			})
+		})
+
+		context("when the database does not have an index", func() {
+			it("returns an error", func() {
+				_, err := scanner.DatabaseVersion(gobuild.VulnerabilityScanConfiguration{
+					Database: database,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to read vulnerability database index:")))
+				Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+			})
+		})
+
+		context("when the index of the database cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(database, "index"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(database, "index", "db.json"), []byte("%%%"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := scanner.DatabaseVersion(gobuild.VulnerabilityScanConfiguration{
+					Database: database,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse vulnerability database index:")))
+			})
+		})
+
+		context("when the index of the database does not record when it was modified", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(database, "index"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(database, "index", "db.json"), []byte("{}"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := scanner.DatabaseVersion(gobuild.VulnerabilityScanConfiguration{
+					Database: database,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("vulnerability database index %s does not record when it was modified", filepath.Join(database, "index", "db.json"))))
+			})
+		})
+
+		context("when govulncheck fails", func() {
+			it.Before(func() {
+				executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+					fmt.Fprintln(execution.Stderr, "govulncheck: some error")
+					return errors.New("exit status 1")
+				}
+			})
+
+			it("returns an error", func() {
+				err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+					Binaries: []string{"bin/some-binary"},
+					Database: database,
+					Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+				})
+				Expect(err).To(MatchError("failed to execute 'govulncheck': exit status 1"))
+
+				Expect(logs.String()).To(ContainSubstring("govulncheck: some error"))
+				Expect(logs.String()).To(ContainSubstring("Failed after 1s"))
+			})
+		})
+
+		context("when the output of govulncheck cannot be parsed", func() {
+			it.Before(func() {
+				executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+					_, err := fmt.Fprint(execution.Stdout, "%%%")
+					return err
+				}
+			})
+
+			it("returns an error", func() {
+				err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+					Binaries: []string{"bin/some-binary"},
+					Database: database,
+					Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse govulncheck output:")))
+			})
+		})
+
+		context("when the database entry cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(database, "ID", "GO-2024-0001.json"), []byte("%%%"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+					Binaries: []string{"bin/some-binary"},
+					Database: database,
+					Report:   filepath.Join(layerPath, "vulnerabilities.json"),
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse vulnerability GO-2024-0001:")))
+			})
+		})
+
+		context("when the report cannot be written", func() {
+			it("returns an error", func() {
+				err := scanner.Scan(gobuild.VulnerabilityScanConfiguration{
+					Binaries: []string{"bin/some-binary"},
+					Database: database,
+					Report:   filepath.Join(layerPath, "missing", "vulnerabilities.json"),
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to write vulnerability report:")))
+			})
+		})
+	})
+}
@@ -0,0 +1,166 @@
+package gobuild
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"golang.org/x/mod/modfile"
+)
+
+// workspaceUseAuto is the BP_GO_WORK_USE value that builds the workspace from
+// every module found in the application.
+const workspaceUseAuto = "auto"
+
+// resolveWorkspaceModules returns the modules that should be added to the
+// go.work file. When the modules are given as "auto" every directory
+// containing a go.mod file is used, otherwise each of the listed modules is
+// checked to be a directory containing a go.mod file.
+func resolveWorkspaceModules(workspace string, modules []string) ([]string, error) {
+	if len(modules) == 1 && modules[0] == workspaceUseAuto {
+		return discoverWorkspaceModules(workspace)
+	}
+
+	for _, module := range modules {
+		path := module
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workspace, module)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to validate workspace module %q: directory does not exist", module)
+			}
+
+			return nil, fmt.Errorf("failed to validate workspace module %q: %w", module, err)
+		}
+
+		if !info.IsDir() {
+			return nil, fmt.Errorf("failed to validate workspace module %q: not a directory", module)
+		}
+
+		hasGoMod, err := fs.Exists(filepath.Join(path, "go.mod"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate workspace module %q: %w", module, err)
+		}
+
+		if !hasGoMod {
+			return nil, fmt.Errorf("failed to validate workspace module %q: directory does not contain a go.mod file", module)
+		}
+	}
+
+	return modules, nil
+}
+
+// discoverWorkspaceModules returns every directory within the workspace that
+// contains a go.mod file, skipping the same directories that the go command
+// ignores when matching packages.
+func discoverWorkspaceModules(workspace string) ([]string, error) {
+	var modules []string
+	err := filepath.WalkDir(workspace, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+
+		if path != workspace {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return filepath.SkipDir
+			}
+		}
+
+		hasGoMod, err := fs.Exists(filepath.Join(path, "go.mod"))
+		if err != nil {
+			return err
+		}
+
+		if hasGoMod {
+			rel, err := filepath.Rel(workspace, path)
+			if err != nil {
+				return err
+			}
+
+			modules = append(modules, cleanTarget(rel))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover workspace modules: %w", err)
+	}
+
+	if len(modules) == 0 {
+		return nil, errors.New("failed to discover workspace modules: no go.mod files could be found")
+	}
+
+	return modules, nil
+}
+
+// findBuildModules returns the directories of every module that the build
+// uses: the workspace itself, the modules of BP_GO_WORK_USE or of the go.work
+// file of the workspace, and otherwise the nested modules that the targets are
+// built from.
+func findBuildModules(workspace string, targets, workspaceModules []string) ([]string, error) {
+	dirs := []string{workspace}
+	add := func(dir string) {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workspace, dir)
+		}
+
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if len(workspaceModules) > 0 {
+		modules, err := resolveWorkspaceModules(workspace, workspaceModules)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, module := range modules {
+			add(module)
+		}
+
+		return dirs, nil
+	}
+
+	goWorkPath := filepath.Join(workspace, "go.work")
+	content, err := os.ReadFile(goWorkPath)
+	if err == nil {
+		goWork, err := modfile.ParseWork(goWorkPath, content, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse go.work: %w", err)
+		}
+
+		for _, use := range goWork.Use {
+			add(use.Path)
+		}
+
+		return dirs, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read go.work: %w", err)
+	}
+
+	modules, err := findTargetModules(GoBuildConfiguration{Workspace: workspace, Targets: targets})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, module := range modules {
+		add(module.dir)
+	}
+
+	return dirs, nil
+}
@@ -0,0 +1,36 @@
+package gobuild
+
+import (
+	"context"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"golang.org/x/vuln/scan"
+)
+
+// Govulncheck runs govulncheck within the buildpack process, so that scanning
+// for vulnerabilities needs neither a separate installation of govulncheck nor
+// network access to fetch one.
+type Govulncheck struct{}
+
+func NewGovulncheck() Govulncheck {
+	return Govulncheck{}
+}
+
+func (g Govulncheck) Execute(execution pexec.Execution) error {
+	cmd := scan.Command(context.Background(), execution.Args...)
+	cmd.Stdin = execution.Stdin
+	if cmd.Stdin == nil {
+		cmd.Stdin = strings.NewReader("")
+	}
+	cmd.Stdout = execution.Stdout
+	cmd.Stderr = execution.Stderr
+	cmd.Env = execution.Env
+
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	return cmd.Wait()
+}
@@ -11,10 +11,15 @@ func TestUnitGoBuild(t *testing.T) {
 	suite := spec.New("go-build", spec.Report(report.Terminal{}))
 	suite("Build", testBuild, spec.Sequential())
 	suite("BuildConfigurationParser", testBuildConfigurationParser, spec.Sequential())
+	suite("BuildInfoSBOMGenerator", testBuildInfoSBOMGenerator)
 	suite("Detect", testDetect, spec.Sequential())
 	suite("GoBuildProcess", testGoBuildProcess)
+	suite("GoLicenseCollector", testGoLicenseCollector)
 	suite("GoPathManager", testGoPathManager)
+	suite("GoProfileResolver", testGoProfileResolver)
 	suite("GoTargetManager", testGoTargetManager)
+	suite("GoToolchain", testGoToolchain)
+	suite("GoVulnerabilityScanner", testGoVulnerabilityScanner)
 	suite("SourceDeleter", testSourceDeleter)
 	suite.Run(t)
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/paketo-buildpacks/occam"
@@ -87,6 +88,11 @@ func testRebuild(t *testing.T, context spec.G, it spec.S) {
 			secondImage, logs, err = build.Execute(name, source)
 			Expect(err).ToNot(HaveOccurred(), logs.String)
 
+			Expect(logs).To(ContainLines(
+				MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, settings.Buildpack.Name)),
+				fmt.Sprintf("  Reusing cached layer /layers/%s/targets", strings.ReplaceAll(settings.Buildpack.ID, "/", "_")),
+			))
+
 			imageIDs[secondImage.ID] = struct{}{}
 
 			container, err = docker.Container.Run.
@@ -0,0 +1,208 @@
+package gobuild
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/mod/modfile"
+)
+
+// ProjectMetadataFileName is the name of the file in the root of the layers
+// directory in which the platform describes the source of the application.
+const ProjectMetadataFileName = "project-metadata.toml"
+
+var versionTagPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+`)
+
+// buildMetadata provides the values of the variables that can be used in
+// ldflags templates, such as -X main.version={{.Version}}. Every value is
+// looked up when the template uses it, so missing metadata only fails the
+// build when it is needed.
+type buildMetadata struct {
+	workspace           string
+	projectMetadataPath string
+}
+
+type projectMetadata struct {
+	Source struct {
+		Version struct {
+			Commit string `toml:"commit"`
+		} `toml:"version"`
+		Metadata struct {
+			Repository string   `toml:"repository"`
+			Refs       []string `toml:"refs"`
+		} `toml:"metadata"`
+	} `toml:"source"`
+}
+
+// Version returns the first version tag, such as v1.2.3, among the refs of
+// the source in the project metadata.
+func (m buildMetadata) Version() (string, error) {
+	metadata, err := m.projectMetadata("Version")
+	if err != nil {
+		return "", err
+	}
+
+	for _, ref := range metadata.Source.Metadata.Refs {
+		if versionTagPattern.MatchString(ref) {
+			return ref, nil
+		}
+	}
+
+	return "", fmt.Errorf("{{.Version}} requires a version tag in source.metadata.refs of %s", ProjectMetadataFileName)
+}
+
+// Commit returns the commit of the source in the project metadata.
+func (m buildMetadata) Commit() (string, error) {
+	metadata, err := m.projectMetadata("Commit")
+	if err != nil {
+		return "", err
+	}
+
+	if metadata.Source.Version.Commit == "" {
+		return "", fmt.Errorf("{{.Commit}} requires source.version.commit in %s", ProjectMetadataFileName)
+	}
+
+	return metadata.Source.Version.Commit, nil
+}
+
+// BuildDate returns the time given by SOURCE_DATE_EPOCH in RFC 3339 format.
+// The current time is never used so that rebuilding the same source produces
+// the same binary.
+func (m buildMetadata) BuildDate() (string, error) {
+	val, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok {
+		return "", errors.New("{{.BuildDate}} requires SOURCE_DATE_EPOCH to be set")
+	}
+
+	seconds, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse SOURCE_DATE_EPOCH: %w", err)
+	}
+
+	return time.Unix(seconds, 0).UTC().Format(time.RFC3339), nil
+}
+
+// ModulePath returns the module path declared in the go.mod file of the
+// workspace.
+func (m buildMetadata) ModulePath() (string, error) {
+	content, err := os.ReadFile(filepath.Join(m.workspace, "go.mod"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", errors.New("{{.ModulePath}} requires a go.mod file")
+		}
+
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+
+	path := modfile.ModulePath(content)
+	if path == "" {
+		return "", errors.New("{{.ModulePath}} requires a module directive in go.mod")
+	}
+
+	return path, nil
+}
+
+func (m buildMetadata) projectMetadata(variable string) (projectMetadata, error) {
+	metadata, ok, err := readProjectMetadata(m.projectMetadataPath)
+	if err != nil {
+		return projectMetadata{}, err
+	}
+
+	if !ok {
+		return projectMetadata{}, fmt.Errorf("{{.%s}} requires the platform to provide %s", variable, ProjectMetadataFileName)
+	}
+
+	return metadata, nil
+}
+
+// readProjectMetadata reads the project metadata from the given path. It is
+// not an error for the platform to leave out the file.
+func readProjectMetadata(path string) (projectMetadata, bool, error) {
+	var metadata projectMetadata
+	_, err := toml.DecodeFile(path, &metadata)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return projectMetadata{}, false, nil
+		}
+
+		return projectMetadata{}, false, fmt.Errorf("failed to parse %s: %w", ProjectMetadataFileName, err)
+	}
+
+	return metadata, true, nil
+}
+
+// renderLDFlags executes the templates in the -ldflags values of the given
+// flags. Values without any template actions are returned unchanged.
+func renderLDFlags(flags []string, metadata buildMetadata) ([]string, error) {
+	rendered := slices.Clone(flags)
+	for i := 0; i < len(rendered); i++ {
+		name, ok := flagName(rendered[i])
+		if !ok || name != "ldflags" {
+			continue
+		}
+
+		index, prefix, ok := flagValue(rendered, i)
+		if !ok {
+			break
+		}
+
+		value := strings.TrimPrefix(rendered[index], prefix)
+		if !strings.Contains(value, "{{") {
+			continue
+		}
+
+		tmpl, err := template.New("ldflags").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ldflags template: %w", err)
+		}
+
+		var buffer bytes.Buffer
+		err = tmpl.Execute(&buffer, metadata)
+		if err != nil {
+			var execErr template.ExecError
+			if errors.As(err, &execErr) && errors.Unwrap(execErr.Err) != nil {
+				err = errors.Unwrap(execErr.Err)
+			}
+			return nil, fmt.Errorf("failed to render ldflags template %q: %w", value, err)
+		}
+
+		rendered[index] = prefix + buffer.String()
+		i = index
+	}
+
+	return rendered, nil
+}
+
+// renderBuildConfiguration renders the ldflags templates of the flags that
+// apply to every target and of the flags of the individual targets.
+func renderBuildConfiguration(configuration BuildConfiguration, metadata buildMetadata) (BuildConfiguration, error) {
+	var err error
+	configuration.Flags, err = renderLDFlags(configuration.Flags, metadata)
+	if err != nil {
+		return BuildConfiguration{}, err
+	}
+
+	if configuration.TargetConfiguration != nil {
+		targetConfiguration := map[string]TargetConfiguration{}
+		for target, settings := range configuration.TargetConfiguration {
+			settings.Flags, err = renderLDFlags(settings.Flags, metadata)
+			if err != nil {
+				return BuildConfiguration{}, fmt.Errorf("target %q: %w", target, err)
+			}
+			targetConfiguration[target] = settings
+		}
+		configuration.TargetConfiguration = targetConfiguration
+	}
+
+	return configuration, nil
+}
@@ -0,0 +1,142 @@
+package gobuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	// ProvenanceFileName is the name of the file in the targets layer that
+	// holds the provenance statement of the binaries.
+	ProvenanceFileName = "provenance.intoto.json"
+
+	// ProvenanceLabel is the image label that holds the provenance statement
+	// when BP_GO_PROVENANCE_LABEL is set.
+	ProvenanceLabel = "io.paketo.go-build.provenance"
+
+	provenanceStatementType = "https://in-toto.io/Statement/v1"
+	provenancePredicateType = "https://slsa.dev/provenance/v1"
+	provenanceBuildType     = "https://github.com/paketo-buildpacks/go-build#provenance"
+)
+
+// provenanceStatement is an in-toto statement with a SLSA provenance
+// predicate, which describes how the binaries in its subject were built.
+type provenanceStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []provenanceResource `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     provenancePredicate  `json:"predicate"`
+}
+
+type provenancePredicate struct {
+	BuildDefinition struct {
+		BuildType          string `json:"buildType"`
+		ExternalParameters struct {
+			Configuration BuildConfiguration `json:"configuration"`
+		} `json:"externalParameters"`
+		InternalParameters struct {
+			Stack    string     `json:"stack"`
+			Commands [][]string `json:"commands"`
+		} `json:"internalParameters"`
+		ResolvedDependencies []provenanceResource `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+	} `json:"runDetails"`
+}
+
+// provenanceResource is a resource descriptor as defined by in-toto.
+type provenanceResource struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// provenanceSource describes the inputs of the build other than its
+// configuration.
+type provenanceSource struct {
+	workspaceSHA string
+	goVersion    string
+	stack        string
+	project      projectMetadata
+}
+
+// newProvenanceStatement describes the build of the given binaries, whose
+// SHA-256 digests are keyed by path, from the resolved configuration and the
+// 'go build' commands that were run.
+func newProvenanceStatement(binaries []string, digests map[string]string, configuration BuildConfiguration, commands [][]string, source provenanceSource, buildpack packit.BuildpackInfo) provenanceStatement {
+	statement := provenanceStatement{
+		Type:          provenanceStatementType,
+		PredicateType: provenancePredicateType,
+	}
+
+	for _, binary := range binaries {
+		statement.Subject = append(statement.Subject, provenanceResource{
+			Name:   binary,
+			Digest: map[string]string{"sha256": digests[binary]},
+		})
+	}
+
+	definition := &statement.Predicate.BuildDefinition
+	definition.BuildType = provenanceBuildType
+	definition.ExternalParameters.Configuration = configuration
+	definition.InternalParameters.Stack = source.stack
+	definition.InternalParameters.Commands = commands
+
+	workspace := provenanceResource{
+		Name:   "workspace",
+		Digest: map[string]string{"sha256": source.workspaceSHA},
+	}
+
+	// The platform describes where the workspace came from when it was
+	// checked out of version control
+	if commit := source.project.Source.Version.Commit; commit != "" {
+		workspace.Digest["gitCommit"] = commit
+		if repository := source.project.Source.Metadata.Repository; repository != "" {
+			workspace.URI = fmt.Sprintf("git+%s@%s", repository, commit)
+		}
+	}
+
+	definition.ResolvedDependencies = []provenanceResource{
+		workspace,
+		{
+			Name:        "go",
+			Annotations: map[string]string{"version": source.goVersion},
+		},
+	}
+
+	statement.Predicate.RunDetails.Builder.ID = fmt.Sprintf("%s@%s", buildpack.ID, buildpack.Version)
+
+	return statement
+}
+
+func writeProvenance(path string, statement provenanceStatement) error {
+	content, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance: %w", err)
+	}
+
+	err = os.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write provenance: %w", err)
+	}
+
+	return nil
+}
+
+// provenanceLabel returns the provenance statement in the compact form used
+// for the image label.
+func provenanceLabel(statement provenanceStatement) (string, error) {
+	content, err := json.Marshal(statement)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode provenance: %w", err)
+	}
+
+	return string(content), nil
+}
@@ -0,0 +1,317 @@
+package gobuild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// defaultSourceDateEpoch is used when the platform does not set
+// SOURCE_DATE_EPOCH. It matches the creation time that the lifecycle gives
+// reproducible images, 1980-01-01T00:00:01Z.
+const defaultSourceDateEpoch = "315532801"
+
+// scrubbedEnvironmentNames are the variables of the build environment that are
+// kept in reproducible mode, on top of the variables that configure the go
+// command and the C toolchain used by cgo.
+var scrubbedEnvironmentNames = map[string]bool{
+	"PATH":          true,
+	"HOME":          true,
+	"TMPDIR":        true,
+	"SSL_CERT_FILE": true,
+	"SSL_CERT_DIR":  true,
+	"HTTP_PROXY":    true,
+	"HTTPS_PROXY":   true,
+	"NO_PROXY":      true,
+	"http_proxy":    true,
+	"https_proxy":   true,
+	"no_proxy":      true,
+}
+
+// outputEnvironmentNames are the variables that affect the contents of the
+// compiled binaries, other than those with the CGO_ prefix. Go command
+// variables are listed by name rather than matched by their GO prefix, which
+// is shared by unrelated variables that may hold credentials, such as
+// GOOGLE_APPLICATION_CREDENTIALS.
+var outputEnvironmentNames = map[string]bool{
+	"GOOS":              true,
+	"GOARCH":            true,
+	"GO386":             true,
+	"GOAMD64":           true,
+	"GOARM":             true,
+	"GOARM64":           true,
+	"GOMIPS":            true,
+	"GOMIPS64":          true,
+	"GOPPC64":           true,
+	"GORISCV64":         true,
+	"GOWASM":            true,
+	"GOEXPERIMENT":      true,
+	"GOFIPS140":         true,
+	"GOFLAGS":           true,
+	"GOTOOLCHAIN":       true,
+	"GOWORK":            true,
+	"GCCGO":             true,
+	"CC":                true,
+	"CXX":               true,
+	"FC":                true,
+	"AR":                true,
+	"PKG_CONFIG":        true,
+	"SOURCE_DATE_EPOCH": true,
+	"TZ":                true,
+	"LC_ALL":            true,
+}
+
+// locationEnvironmentNames are go command variables that only point at
+// directories or module sources, or tune the go command itself, none of which
+// ends up in the binaries.
+var locationEnvironmentNames = map[string]bool{
+	"GOCACHE":        true,
+	"GOENV":          true,
+	"GOINSECURE":     true,
+	"GOMODCACHE":     true,
+	"GONOPROXY":      true,
+	"GONOSUMDB":      true,
+	"GOPATH":         true,
+	"GOPRIVATE":      true,
+	"GOPROXY":        true,
+	"GOROOT":         true,
+	"GOSUMDB":        true,
+	"GOTMPDIR":       true,
+	"GOVCS":          true,
+	"GOAUTH":         true,
+	"GODEBUG":        true,
+	"GOMAXPROCS":     true,
+	"GOTELEMETRY":    true,
+	"GOCOVERDIR":     true,
+	"GOGC":           true,
+	"GOMEMLIMIT":     true,
+	"GOTRACEBACK":    true,
+	"GOTELEMETRYDIR": true,
+}
+
+// isOutputEnvironmentName reports whether the variable affects the contents of
+// the compiled binaries.
+func isOutputEnvironmentName(name string) bool {
+	return outputEnvironmentNames[name] || strings.HasPrefix(name, "CGO_")
+}
+
+func sourceDateEpoch() string {
+	if val, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok && val != "" {
+		return val
+	}
+
+	return defaultSourceDateEpoch
+}
+
+// reproducibleFlags adds the flags that remove the remaining sources of
+// variation from the binaries: the build ID, which is derived from the
+// location of the inputs, and the version control information.
+func reproducibleFlags(flags []string) []string {
+	flags = slices.Clone(flags)
+	if !containsFlag(flags, "-buildvcs") {
+		flags = append(flags, "-buildvcs=false")
+	}
+
+	for i := 0; i < len(flags); i++ {
+		name, ok := flagName(flags[i])
+		if !ok || name != "ldflags" {
+			continue
+		}
+
+		index, prefix, ok := flagValue(flags, i)
+		if !ok {
+			break
+		}
+
+		value := strings.TrimPrefix(flags[index], prefix)
+		if !strings.Contains(value, "-buildid") {
+			flags[index] = prefix + strings.TrimSpace(value+" -buildid=")
+		}
+
+		return flags
+	}
+
+	return append(flags, "-ldflags=-buildid=")
+}
+
+// scrubEnvironment removes the variables that differ from one build machine to
+// the next and are not needed by the go command.
+func scrubEnvironment(environ []string) []string {
+	var env []string
+	for _, variable := range environ {
+		name, _, _ := strings.Cut(variable, "=")
+		if scrubbedEnvironmentNames[name] || locationEnvironmentNames[name] || isOutputEnvironmentName(name) {
+			env = append(env, variable)
+		}
+	}
+
+	return env
+}
+
+// outputEnvironment returns the variables of the given environment that affect
+// the compiled binaries, sorted by name. When a variable is given more than
+// once the last value is used, as it is by the go command.
+func outputEnvironment(environ []string) []string {
+	values := map[string]string{}
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		if isOutputEnvironmentName(name) {
+			values[name] = value
+		}
+	}
+
+	var env []string
+	for name, value := range values {
+		env = append(env, fmt.Sprintf("%s=%s", name, value))
+	}
+	slices.Sort(env)
+
+	return env
+}
+
+// setSourceDateEpoch gives the binaries the modification time from
+// SOURCE_DATE_EPOCH.
+func setSourceDateEpoch(paths []string, epoch string) error {
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse SOURCE_DATE_EPOCH: %w", err)
+	}
+
+	timestamp := time.Unix(seconds, 0)
+	for _, path := range paths {
+		err = os.Chtimes(path, timestamp, timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to set modification time of %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// reproducibilityRecord describes every input of a reproducible build other
+// than the source code, so that the build can be repeated and audited. The
+// environment is the one that was passed to 'go build'.
+func reproducibilityRecord(config GoBuildConfiguration, env []string, goVersion, stack string) map[string]interface{} {
+	var targets []map[string]interface{}
+	for _, target := range config.Targets {
+		configuration := config.TargetConfiguration[target]
+		record := map[string]interface{}{
+			"target": target,
+			"flags":  buildFlags(config, configuration),
+		}
+
+		if len(configuration.Env) > 0 {
+			record["env"] = configuration.Env
+		}
+
+		targets = append(targets, record)
+	}
+
+	record := map[string]interface{}{
+		"go_version": goVersion,
+		"stack":      stack,
+		"env":        env,
+		"targets":    targets,
+	}
+
+	// The binaries are then relinked without their debug symbols, as
+	// described by buildWithDebugSymbols
+	if config.DebugSymbolsOutput != "" {
+		record["split_debug_symbols"] = true
+	}
+
+	return record
+}
+
+// verifyReproducible builds the targets a second time into a scratch
+// directory with an empty build cache and compares the SHA-256 digests of the
+// binaries with those from the first build. Nothing from the first build can
+// be reused, so any difference comes from the build itself.
+func (p GoBuildProcess) verifyReproducible(config GoBuildConfiguration, modules []targetModule, names map[string]string, env []string) error {
+	p.logs.Subprocess("Verifying that the build is reproducible")
+
+	scratch, err := os.MkdirTemp("", "go-build-verify")
+	if err != nil {
+		return fmt.Errorf("failed to create verification directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	verification := config
+	verification.Output = filepath.Join(scratch, "bin")
+	verification.GoCache = filepath.Join(scratch, "gocache")
+	if config.DebugSymbolsOutput != "" {
+		verification.DebugSymbolsOutput = filepath.Join(scratch, "symbols")
+	}
+
+	for _, dir := range []string{verification.Output, verification.GoCache} {
+		err = os.MkdirAll(dir, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to create verification directory: %w", err)
+		}
+	}
+
+	var verificationEnv []string
+	for _, variable := range env {
+		if !strings.HasPrefix(variable, "GOCACHE=") {
+			verificationEnv = append(verificationEnv, variable)
+		}
+	}
+	verificationEnv = append(verificationEnv, fmt.Sprintf("GOCACHE=%s", verification.GoCache))
+
+	_, _, err = p.build(verification, modules, names, verificationEnv)
+	if err != nil {
+		return err
+	}
+
+	var differences []string
+	for _, target := range config.Targets {
+		name := names[target]
+		first, err := fileDigest(filepath.Join(config.Output, name))
+		if err != nil {
+			return err
+		}
+
+		second, err := fileDigest(filepath.Join(verification.Output, name))
+		if err != nil {
+			return err
+		}
+
+		if first != second {
+			differences = append(differences, fmt.Sprintf("%s: sha256:%s (first build) != sha256:%s (second build)", name, first, second))
+			continue
+		}
+
+		p.logs.Action("%s: sha256:%s", name, first)
+	}
+
+	if len(differences) > 0 {
+		return packit.Fail.WithMessage("the build is not reproducible, the following binaries differ between builds:\n  %s", strings.Join(differences, "\n  "))
+	}
+
+	return nil
+}
+
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open binary: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute digest of %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
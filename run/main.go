@@ -1,42 +1,51 @@
 package main
 
 import (
+	"io"
 	"os"
 
 	gobuild "github.com/paketo-buildpacks/go-build"
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/pexec"
-	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 )
 
-type Generator struct{}
-
-func (f Generator) Generate(dir string) (sbom.SBOM, error) {
-	return sbom.Generate(dir)
-}
-
 func main() {
 	emitter := scribe.NewEmitter(os.Stdout).WithLevel(os.Getenv("BP_LOG_LEVEL"))
-	configParser := gobuild.NewBuildConfigurationParser(gobuild.NewGoTargetManager())
+	goExecutable := pexec.NewExecutable("go")
 
 	packit.Run(
 		gobuild.Detect(
-			configParser,
+			// Target discovery is only reported during the build
+			gobuild.NewBuildConfigurationParser(gobuild.NewGoTargetManager(scribe.NewEmitter(io.Discard))),
 		),
 		gobuild.Build(
-			configParser,
+			gobuild.NewBuildConfigurationParser(gobuild.NewGoTargetManager(emitter)),
 			gobuild.NewGoBuildProcess(
-				pexec.NewExecutable("go"),
+				goExecutable,
 				emitter,
 				chronos.DefaultClock,
+				servicebindings.NewResolver(),
 			),
 			gobuild.NewGoPathManager(os.TempDir()),
 			chronos.DefaultClock,
 			emitter,
 			gobuild.NewSourceDeleter(),
-			Generator{},
+			gobuild.NewBuildInfoSBOMGenerator(),
+			fs.NewChecksumCalculator(),
+			gobuild.NewGoToolchain(goExecutable),
+			gobuild.NewGoVulnerabilityScanner(
+				gobuild.NewGovulncheck(),
+				emitter,
+				chronos.DefaultClock,
+				servicebindings.NewResolver(),
+			),
+			gobuild.NewGoLicenseCollector(goExecutable, emitter),
+			gobuild.NewGoProfileResolver(servicebindings.NewResolver()),
+			servicebindings.NewResolver(),
 		),
 	)
 }
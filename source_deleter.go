@@ -13,12 +13,7 @@ func NewSourceDeleter() SourceDeleter {
 	return SourceDeleter{}
 }
 
-func (d SourceDeleter) Clear(path string) error {
-	var envGlobs []string
-	if val, ok := os.LookupEnv("BP_KEEP_FILES"); ok {
-		envGlobs = append(envGlobs, filepath.SplitList(val)...)
-	}
-
+func (d SourceDeleter) Clear(path string, keepFiles []string) error {
 	// This is logic taken from github.com/ForestEckhardt/source-removal/build.go
 	//
 	// The following constructs a set of all the file paths that are required
@@ -29,7 +24,7 @@ func (d SourceDeleter) Clear(path string) error {
 	// Input: "public/data/*"
 	// Output: ["path/public", "path/public/data", "path/public/data/*"]
 	var globs = []string{path}
-	for _, glob := range envGlobs {
+	for _, glob := range keepFiles {
 		dirs := strings.Split(glob, string(os.PathSeparator))
 		for i := range dirs {
 			globs = append(globs, filepath.Join(path, filepath.Join(dirs[:i+1]...)))
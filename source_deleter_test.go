@@ -39,7 +39,7 @@ func testSourceDeleter(t *testing.T, context spec.G, it spec.S) {
 	})
 
 	it("deletes the source code from the given directory path", func() {
-		Expect(deleter.Clear(path)).To(Succeed())
+		Expect(deleter.Clear(path, nil)).To(Succeed())
 
 		paths, err := filepath.Glob(filepath.Join(path, "*"))
 		Expect(err).NotTo(HaveOccurred())
@@ -47,16 +47,8 @@ func testSourceDeleter(t *testing.T, context spec.G, it spec.S) {
 	})
 
 	context("when there are files to keep", func() {
-		it.Before(func() {
-			Expect(os.Setenv("BP_KEEP_FILES", `some-dir/some-other-dir/*:some-file`)).To(Succeed())
-		})
-
-		it.After(func() {
-			Expect(os.Unsetenv("BP_KEEP_FILES")).To(Succeed())
-		})
-
 		it("returns a result that deletes the contents of the working directroy except for the file that are meant to kept", func() {
-			Expect(deleter.Clear(path)).To(Succeed())
+			Expect(deleter.Clear(path, []string{"some-dir/some-other-dir/*", "some-file"})).To(Succeed())
 
 			Expect(path).To(BeADirectory())
 			Expect(filepath.Join(path, "some-file")).To(BeAnExistingFile())
@@ -69,16 +61,8 @@ func testSourceDeleter(t *testing.T, context spec.G, it spec.S) {
 
 	context("failure cases", func() {
 		context("when the path is malformed", func() {
-			it.Before(func() {
-				Expect(os.Setenv("BP_KEEP_FILES", `\`)).To(Succeed())
-			})
-
-			it.After(func() {
-				Expect(os.Unsetenv("BP_KEEP_FILES")).To(Succeed())
-			})
-
 			it("returns an error", func() {
-				err := deleter.Clear(path)
+				err := deleter.Clear(path, []string{`\`})
 
 				Expect(err).To(MatchError(ContainSubstring("failed to remove source:")))
 				Expect(err).To(MatchError(ContainSubstring("syntax error in pattern")))